@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
 	"syscall"
@@ -19,7 +20,131 @@ import (
 
 func main() {
 	registry := commands.NewRegistry()
+	args := os.Args[1:]
 
+	// A name starting with '-' is how login(1) starts a login shell, and
+	// running as sh asks for POSIX behaviour
+	login := strings.HasPrefix(registry.Name, "-")
+	posix := filepath.Base(strings.TrimPrefix(registry.Name, "-")) == "sh"
+	norc := false
+	rcfile := ""
+
+	// Options for the shell itself come before -c or a script
+options:
+	for len(args) > 0 {
+		switch args[0] {
+		case "-l", "--login":
+			login = true
+		case "--norc":
+			norc = true
+		case "--posix":
+			posix = true
+		case "--rcfile", "--init-file":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "%s: %s: option requires an argument\n", registry.Name, args[0])
+				os.Exit(2)
+			}
+			rcfile = args[1]
+			args = args[1:]
+		case "--":
+			args = args[1:]
+			break options
+		default:
+			break options
+		}
+		args = args[1:]
+	}
+
+	if login {
+		loadProfile(registry)
+	}
+
+	switch {
+	case len(args) > 0 && args[0] == "-c":
+		// shell -c 'commands' [name [args...]]
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "%s: -c: option requires an argument\n", registry.Name)
+			os.Exit(2)
+		}
+		if len(args) > 2 {
+			registry.Name = args[2]
+			registry.Positional = args[3:]
+		}
+		os.Exit(executor.RunScript(strings.NewReader(args[1]), registry.Name, registry, os.Stdin, os.Stdout, os.Stderr))
+
+	case len(args) > 0:
+		// shell file [args...]
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: No such file or directory\n", registry.Name, args[0])
+			os.Exit(127)
+		}
+		registry.Name = args[0]
+		registry.Positional = args[1:]
+		status := executor.RunScript(f, args[0], registry, os.Stdin, os.Stdout, os.Stderr)
+		f.Close()
+		os.Exit(status)
+
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		// Commands piped in on stdin
+		os.Exit(executor.RunScript(os.Stdin, registry.Name, registry, os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	registry.Interactive = true
+	switch {
+	case posix:
+		// POSIX shells read the file named by $ENV instead of an rc file
+		if env, ok := registry.Vars.Get("ENV"); ok {
+			sourceStartup(registry, os.Expand(env, func(name string) string {
+				v, _ := registry.Vars.Get(name)
+				return v
+			}))
+		}
+	case rcfile != "":
+		sourceStartup(registry, rcfile)
+	case !norc && !login:
+		if home, ok := registry.TildeDir(""); ok {
+			sourceStartup(registry, filepath.Join(home, ".goshrc"))
+		}
+	}
+	os.Exit(interactive(registry))
+}
+
+// loadProfile runs the startup files of a login shell: /etc/profile, then
+// ~/.gosh_profile or, if there is none, ~/.profile.
+func loadProfile(registry *commands.Registry) {
+	sourceStartup(registry, "/etc/profile")
+	home, ok := registry.TildeDir("")
+	if !ok {
+		return
+	}
+	for _, name := range []string{".gosh_profile", ".profile"} {
+		if sourceStartup(registry, filepath.Join(home, name)) {
+			return
+		}
+	}
+}
+
+// sourceStartup runs a startup file in the shell if it exists, and reports
+// whether it did. exit in a startup file ends the shell.
+func sourceStartup(registry *commands.Registry, path string) bool {
+	if path == "" {
+		return false
+	}
+	path = registry.Path(path)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	registry.Source(path, nil, os.Stdin, os.Stdout, os.Stderr)
+	if registry.ExitSignal {
+		os.Exit(registry.ExitCode)
+	}
+	return true
+}
+
+// interactive reads commands from the terminal with line editing, history
+// and completion, and returns the shell's exit status.
+func interactive(registry *commands.Registry) int {
 	histFile := os.Getenv("HISTFILE")
 	if histFile != "" {
 		registry.History.InitFromFile(histFile, os.Stderr)
@@ -27,7 +152,8 @@ func main() {
 
 	oldState, err := term.EnableRawMode(int(os.Stdin.Fd()))
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
 	defer term.RestoreTerminal(int(os.Stdin.Fd()), oldState)
 
@@ -46,9 +172,17 @@ func main() {
 
 	reader := bufio.NewReader(os.Stdin)
 
+	// pending holds the earlier lines of a command that is still incomplete
+	// (e.g. an unclosed quote), joined with newlines.
+	var pending strings.Builder
+
 	for {
-		registry.ReapJobs(os.Stdout,true)
-		fmt.Print("$ ")
+		if pending.Len() == 0 {
+			registry.ReapJobs(os.Stdout,true)
+			fmt.Print("$ ")
+		} else {
+			fmt.Print("> ")
+		}
 
 		var line strings.Builder
 		tabCount := 0
@@ -56,7 +190,7 @@ func main() {
 		for {
 			ch, err := reader.ReadByte()
 			if err != nil {
-				return
+				return registry.LastStatus
 			}
 
 			switch ch {
@@ -149,33 +283,47 @@ func main() {
 		}
 
 	EXECUTE:
-		cmdLine := strings.TrimSpace(line.String())
+		pending.WriteString(line.String())
+		cmdLine := strings.TrimSpace(pending.String())
 		if cmdLine == "" {
+			pending.Reset()
 			continue
 		}
 
-		registry.History.Add(cmdLine)
-
 		// Lexing
 		l := lexer.New(cmdLine)
 		
 		// Parsing (Build AST)
 		p := parser.New(l)
+		p.SetAliases(registry.Aliases)
 		program := p.Parse()
 
+		// Ask for another line until the command is complete
+		if p.Incomplete() {
+			pending.WriteString("\n")
+			continue
+		}
+		pending.Reset()
+
+		registry.History.Add(cmdLine)
+
+		if errs := p.Errors(); len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s\n", e)
+			}
+			continue
+		}
+
 		// Execution (Recursively Walk AST)
 		if program != nil {
-			err := executor.Execute(program, registry, os.Stdin, os.Stdout, os.Stderr)
-			if err != nil {
-				continue
-			}
+			executor.Execute(program, registry, os.Stdin, os.Stdout, os.Stderr)
 		}
 
 		if registry.ExitSignal {
 			if histFile != "" {
 				registry.History.WriteFile(histFile, os.Stderr)
 			}
-			return
+			return registry.ExitCode
 		}
 	}
 }
\ No newline at end of file
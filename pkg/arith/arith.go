@@ -0,0 +1,547 @@
+package arith
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Env gives the evaluator access to shell variables.
+type Env interface {
+	Get(name string) (string, bool)
+	Set(name, value string) error
+}
+
+// maxDepth bounds how deep variable values may be evaluated as expressions
+// themselves (x=y, y=x would otherwise recurse forever).
+const maxDepth = 1024
+
+// Eval evaluates an arithmetic expression with 64-bit integers, reading and
+// assigning variables through env. An empty expression evaluates to 0.
+func Eval(expr string, env Env) (int64, error) {
+	return eval(expr, env, 0)
+}
+
+func eval(expr string, env Env, depth int) (int64, error) {
+	if depth > maxDepth {
+		return 0, fmt.Errorf("expression recursion level exceeded")
+	}
+	p := &parser{src: expr, env: env, depth: depth}
+	p.next()
+	if p.tok.kind == tokEOF {
+		return 0, nil
+	}
+	v := p.parseExpr()
+	if p.err == nil && p.tok.kind != tokEOF {
+		p.fail("syntax error in expression")
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+	return v, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokIdent
+	tokOp
+)
+
+type tok struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+// operators is ordered so that longer operators are matched first.
+var operators = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"<<", ">>", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~", "&", "|", "^",
+	"?", ":", ",", "(", ")",
+}
+
+// assignOps are the assignment operators; the compound ones apply the
+// operator before the '=' to the old value.
+var assignOps = []string{"=", "+=", "-=", "*=", "/=", "%=", "<<=", ">>=", "&=", "|=", "^="}
+
+type parser struct {
+	src   string
+	pos   int
+	tok   tok
+	env   Env
+	depth int
+	err   error
+	// skip > 0 while parsing operands that short-circuiting does not evaluate
+	skip int
+	// rhs is where the right operand of the operator being applied starts,
+	// for errors like division by 0 that point at it
+	rhs int
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = tok{kind: tokEOF, pos: p.pos}
+		return
+	}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c):
+		for p.pos < len(p.src) && isAlnum(p.src[p.pos]) {
+			p.pos++
+		}
+		// base#digits, where digits may also use '@' and '_'
+		if p.pos < len(p.src) && p.src[p.pos] == '#' {
+			p.pos++
+			for p.pos < len(p.src) && (isAlnum(p.src[p.pos]) || p.src[p.pos] == '@') {
+				p.pos++
+			}
+		}
+		p.tok = tok{kind: tokNum, text: p.src[start:p.pos], pos: start}
+		return
+	case isAlpha(c):
+		for p.pos < len(p.src) && isAlnum(p.src[p.pos]) {
+			p.pos++
+		}
+		// An array element "name[expr]" is a single identifier; the
+		// environment evaluates the subscript
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			depth := 0
+			for ; p.pos < len(p.src); p.pos++ {
+				if p.src[p.pos] == '[' {
+					depth++
+				} else if p.src[p.pos] == ']' {
+					if depth--; depth == 0 {
+						p.pos++
+						break
+					}
+				}
+			}
+			if depth > 0 {
+				p.tok = tok{kind: tokOp, text: p.src[start:], pos: start}
+				p.fail("bad array subscript")
+				return
+			}
+		}
+		p.tok = tok{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+		return
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			p.tok = tok{kind: tokOp, text: op, pos: start}
+			return
+		}
+	}
+	p.pos = len(p.src)
+	p.tok = tok{kind: tokOp, text: p.src[start:], pos: start}
+	p.fail("syntax error: operand expected")
+}
+
+// fail records the first error, pointing at the current token like bash does.
+func (p *parser) fail(msg string) {
+	p.failAt(p.tok.pos, msg)
+}
+
+func (p *parser) failAt(pos int, msg string) {
+	if p.err != nil {
+		return
+	}
+	rest := strings.TrimSpace(p.src[pos:])
+	p.err = fmt.Errorf("%s (error token is \"%s\")", msg, rest)
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpr parses a full expression: assignments separated by commas. The
+// value is that of the last one.
+func (p *parser) parseExpr() int64 {
+	v := p.parseAssign()
+	for p.isOp(",") {
+		p.next()
+		v = p.parseAssign()
+	}
+	return v
+}
+
+// parseAssign handles "name = expr" and the compound assignment operators.
+func (p *parser) parseAssign() int64 {
+	if p.tok.kind == tokIdent {
+		save, savePos := p.tok, p.pos
+		name := p.tok.text
+		p.next()
+		if p.isOp(assignOps...) {
+			op := p.tok.text
+			p.next()
+			rhsPos := p.tok.pos
+			rhs := p.parseAssign()
+			if p.err != nil {
+				return 0
+			}
+			v := rhs
+			if op != "=" {
+				p.rhs = rhsPos
+				v = p.binary(strings.TrimSuffix(op, "="), p.lookup(name), rhs)
+			}
+			p.assign(name, v)
+			return v
+		}
+		p.tok, p.pos = save, savePos
+	}
+	v := p.parseTernary()
+	if p.isOp(assignOps...) {
+		p.fail("attempted assignment to non-variable")
+	}
+	return v
+}
+
+// parseTernary handles "cond ? a : b". Only the branch that is chosen is
+// evaluated.
+func (p *parser) parseTernary() int64 {
+	cond := p.parseOr()
+	if !p.isOp("?") {
+		return cond
+	}
+	p.next()
+	if cond == 0 {
+		p.skip++
+	}
+	a := p.parseExpr()
+	if cond == 0 {
+		p.skip--
+	}
+	if !p.isOp(":") {
+		p.fail("`:' expected for conditional expression")
+		return 0
+	}
+	p.next()
+	if cond != 0 {
+		p.skip++
+	}
+	b := p.parseTernary()
+	if cond != 0 {
+		p.skip--
+	}
+	if cond != 0 {
+		return a
+	}
+	return b
+}
+
+func (p *parser) parseOr() int64 {
+	left := p.parseAnd()
+	for p.isOp("||") {
+		p.next()
+		if left != 0 {
+			p.skip++
+		}
+		right := p.parseAnd()
+		if left != 0 {
+			p.skip--
+		}
+		left = bool2int(left != 0 || right != 0)
+	}
+	return left
+}
+
+func (p *parser) parseAnd() int64 {
+	left := p.parseBinary(0)
+	for p.isOp("&&") {
+		p.next()
+		if left == 0 {
+			p.skip++
+		}
+		right := p.parseBinary(0)
+		if left == 0 {
+			p.skip--
+		}
+		left = bool2int(left != 0 && right != 0)
+	}
+	return left
+}
+
+// binaryLevels lists the left-associative binary operators between && and
+// the multiplicative ones, from the loosest binding to the tightest.
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) int64 {
+	if level == len(binaryLevels) {
+		return p.parsePower()
+	}
+	left := p.parseBinary(level + 1)
+	for p.isOp(binaryLevels[level]...) {
+		op := p.tok.text
+		p.next()
+		rhsPos := p.tok.pos
+		right := p.parseBinary(level + 1)
+		p.rhs = rhsPos
+		left = p.binary(op, left, right)
+	}
+	return left
+}
+
+// parsePower handles "**", which is right-associative.
+func (p *parser) parsePower() int64 {
+	left := p.parseUnary()
+	if p.isOp("**") {
+		p.next()
+		rhsPos := p.tok.pos
+		right := p.parsePower()
+		p.rhs = rhsPos
+		return p.binary("**", left, right)
+	}
+	return left
+}
+
+func (p *parser) parseUnary() int64 {
+	if p.isOp("++", "--") {
+		op := p.tok.text
+		p.next()
+		if p.tok.kind != tokIdent {
+			p.fail("syntax error: operand expected")
+			return 0
+		}
+		name := p.tok.text
+		p.next()
+		v := p.lookup(name) + 1
+		if op == "--" {
+			v = p.lookup(name) - 1
+		}
+		p.assign(name, v)
+		return v
+	}
+	if p.isOp("!", "~", "-", "+") {
+		op := p.tok.text
+		p.next()
+		v := p.parseUnary()
+		switch op {
+		case "!":
+			return bool2int(v == 0)
+		case "~":
+			return ^v
+		case "-":
+			return -v
+		}
+		return v
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() int64 {
+	if p.tok.kind == tokIdent {
+		name := p.tok.text
+		p.next()
+		v := p.lookup(name)
+		if p.isOp("++", "--") {
+			if p.tok.text == "++" {
+				p.assign(name, v+1)
+			} else {
+				p.assign(name, v-1)
+			}
+			p.next()
+		}
+		return v
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() int64 {
+	switch {
+	case p.tok.kind == tokNum:
+		v, err := parseNumber(p.tok.text)
+		if err != nil {
+			p.fail(err.Error())
+			return 0
+		}
+		p.next()
+		return v
+	case p.isOp("("):
+		p.next()
+		v := p.parseExpr()
+		if !p.isOp(")") {
+			p.fail("missing `)'")
+			return 0
+		}
+		p.next()
+		return v
+	}
+	p.fail("syntax error: operand expected")
+	return 0
+}
+
+func (p *parser) binary(op string, a, b int64) int64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "**":
+		if b < 0 {
+			if p.skip == 0 {
+				p.failAt(p.rhs, "exponent less than 0")
+			}
+			return 0
+		}
+		v := int64(1)
+		for ; b > 0; b-- {
+			v *= a
+		}
+		return v
+	case "<<":
+		return a << (uint64(b) & 63)
+	case ">>":
+		return a >> (uint64(b) & 63)
+	case "&":
+		return a & b
+	case "|":
+		return a | b
+	case "^":
+		return a ^ b
+	case "/", "%":
+		if b == 0 {
+			if p.skip == 0 {
+				p.failAt(p.rhs, "division by 0")
+			}
+			return 0
+		}
+		if op == "/" {
+			return a / b
+		}
+		return a % b
+	case "<":
+		return bool2int(a < b)
+	case "<=":
+		return bool2int(a <= b)
+	case ">":
+		return bool2int(a > b)
+	case ">=":
+		return bool2int(a >= b)
+	case "==":
+		return bool2int(a == b)
+	case "!=":
+		return bool2int(a != b)
+	}
+	return 0
+}
+
+// lookup returns the value of a variable. Like bash, a variable's value is
+// itself evaluated as an expression, and unset or empty variables are 0.
+func (p *parser) lookup(name string) int64 {
+	if p.skip > 0 || p.err != nil {
+		return 0
+	}
+	value, _ := p.env.Get(name)
+	if strings.TrimSpace(value) == "" {
+		return 0
+	}
+	v, err := eval(value, p.env, p.depth+1)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *parser) assign(name string, v int64) {
+	if p.skip > 0 || p.err != nil {
+		return
+	}
+	if err := p.env.Set(name, strconv.FormatInt(v, 10)); err != nil {
+		p.err = err
+	}
+}
+
+// parseNumber converts a numeric constant: decimal, octal with a leading 0,
+// hexadecimal with 0x, or "base#digits" for bases 2 to 64. Digits above 9
+// are the letters, then '@' and '_'; up to base 36 letters ignore case.
+func parseNumber(text string) (int64, error) {
+	base := int64(10)
+	digits := text
+	if b, rest, ok := strings.Cut(text, "#"); ok {
+		n, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || n < 2 || n > 64 {
+			return 0, fmt.Errorf("invalid arithmetic base")
+		}
+		base, digits = n, rest
+	} else if len(text) > 1 && text[0] == '0' {
+		if text[1] == 'x' || text[1] == 'X' {
+			base, digits = 16, text[2:]
+		} else {
+			base, digits = 8, text[1:]
+		}
+	}
+	if digits == "" {
+		return 0, fmt.Errorf("invalid number")
+	}
+
+	var v int64
+	for i := 0; i < len(digits); i++ {
+		d := digitValue(digits[i], base)
+		if d < 0 {
+			return 0, fmt.Errorf("invalid number")
+		}
+		if d >= base {
+			return 0, fmt.Errorf("value too great for base")
+		}
+		v = v*base + d
+	}
+	return v, nil
+}
+
+func digitValue(c byte, base int64) int64 {
+	switch {
+	case isDigit(c):
+		return int64(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int64(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		if base <= 36 {
+			return int64(c-'A') + 10
+		}
+		return int64(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+func bool2int(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isAlpha(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isAlnum(c byte) bool { return isDigit(c) || isAlpha(c) }
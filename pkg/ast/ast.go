@@ -6,28 +6,99 @@ type Node interface {
 	String() string
 }
 
+// CommandNode is a simple command. Assigns holds the leading NAME=value
+// words: on their own they set shell variables, before a command they only
+// apply to that command's environment.
 type CommandNode struct {
-	Args []string
+	Assigns []*Assignment
+	Args    []*Word
 }
 
+// PipeNode is a pipeline: each stage's output is the next one's input.
+// Negate and Time apply to the whole pipeline, as in "! a | b" and
+// "time a | b"; a single command with one of them is a one-stage PipeNode.
 type PipeNode struct {
-	Left  Node
-	Right Node
+	Stages []Node
+
+	Negate    bool // "!": invert the exit status
+	Time      bool // "time": report how long the pipeline took
+	TimePOSIX bool // "time -p": report it in the POSIX format
 }
 
 type RedirectNode struct {
 	Stmt     Node
-	Location string // Filename
+	Location *Word  // Filename
 	Type     string // >, >>, 1>, 2>
 	Fd       int    // 1 for stdout, 2 for stderr
 }
 
+// IfNode is an if statement. An elif chain is stored as a nested IfNode in
+// Else, so "if a; then b; elif c; then d; fi" has Else = IfNode{c, d, nil}.
 type IfNode struct {
 	Condition Node
 	Then Node
 	Else Node
 }
 
+// WhileNode is a while loop, or an until loop when Until is set.
+type WhileNode struct {
+	Condition Node
+	Body      Node
+	Until     bool
+}
+
+// ForNode is "for Name in Words; do Body; done". Words is nil when the in
+// clause is left out, which loops over the positional parameters.
+type ForNode struct {
+	Name  string
+	Words []*Word
+	Body  Node
+}
+
+// ArithForNode is the C-style "for ((Init; Cond; Step)); do Body; done".
+// An empty Cond is always true.
+type ArithForNode struct {
+	Init string
+	Cond string
+	Step string
+	Body Node
+}
+
+// CaseNode is "case Word in Items esac".
+type CaseNode struct {
+	Word  *Word
+	Items []CaseItem
+}
+
+// CaseItem is one "pattern|pattern) Body ;;" clause of a case statement.
+// Terminator is ";;", ";&" (fall through into the next body) or ";;&" (go on
+// testing the following patterns).
+type CaseItem struct {
+	Patterns   []*Word
+	Body       Node
+	Terminator string
+}
+
+// ArithNode is the "((Expr))" command. It succeeds when Expr evaluates to a
+// non-zero value.
+type ArithNode struct {
+	Expr string
+}
+
+// FunctionNode defines a shell function; running it stores Body under Name.
+type FunctionNode struct {
+	Name string
+	Body Node
+}
+
+// SubshellNode is "( Body )". Body runs in a copy of the shell state, so
+// variable assignments and cd inside it do not affect the parent.
+type SubshellNode struct {
+	Body Node
+}
+
+// BlockNode is a list of statements; it is also used for "{ list; }" groups,
+// which run in the current shell.
 type BlockNode struct {
 	Statements []Node
 }
@@ -39,17 +110,42 @@ type BinaryNode struct {
 }
 
 func (c *CommandNode) String() string {
-	return strings.Join(c.Args, " ")
+	var words []string
+	for _, a := range c.Assigns {
+		words = append(words, a.Raw)
+	}
+	for _, w := range c.Args {
+		words = append(words, w.Raw)
+	}
+	return strings.Join(words, " ")
 }
 
 func (p *PipeNode) String() string {
-	return " | "
+	var stages []string
+	for _, stage := range p.Stages {
+		if stage != nil {
+			stages = append(stages, stage.String())
+		}
+	}
+	return strings.Join(stages, " | ")
 }
 
 func (r *RedirectNode) String() string {
-	return " " + r.Type + " " + r.Location
+	return " " + r.Type + " " + r.Location.Raw
 }
 
 func (i *IfNode) String() string { return "IF" }
+func (w *WhileNode) String() string {
+	if w.Until {
+		return "UNTIL"
+	}
+	return "WHILE"
+}
+func (f *ForNode) String() string { return "FOR" }
+func (f *ArithForNode) String() string { return "FOR" }
+func (c *CaseNode) String() string { return "CASE" }
+func (a *ArithNode) String() string { return "((" + a.Expr + "))" }
+func (f *FunctionNode) String() string { return f.Name + " ()" }
+func (s *SubshellNode) String() string { return "SUBSHELL" }
 func (b *BlockNode) String() string { return "BLOCK" }
 func (b *BinaryNode) String() string { return b.Operator }
\ No newline at end of file
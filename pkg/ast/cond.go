@@ -0,0 +1,52 @@
+package ast
+
+// CondNode is the "[[ Expr ]]" command. Its words are expanded without
+// word splitting or pathname expansion.
+type CondNode struct {
+	Expr CondExpr
+}
+
+func (c *CondNode) String() string { return "[[" }
+
+// CondExpr is an expression inside [[ ]].
+type CondExpr interface {
+	condExpr()
+}
+
+// CondBinary is "Left && Right" or "Left || Right".
+type CondBinary struct {
+	Op    string
+	Left  CondExpr
+	Right CondExpr
+}
+
+// CondNot is "! X".
+type CondNot struct {
+	X CondExpr
+}
+
+// CondUnary is a unary test such as "-f file" or "-z string".
+type CondUnary struct {
+	Op  string
+	Arg *Word
+}
+
+// CondCompare is a binary test. With "==", "=" and "!=" Right is a
+// pattern, with "=~" a regular expression; quoted parts of it match
+// literally.
+type CondCompare struct {
+	Op    string
+	Left  *Word
+	Right *Word
+}
+
+// CondWord is a word on its own, which is true when it is not empty.
+type CondWord struct {
+	Word *Word
+}
+
+func (*CondBinary) condExpr()  {}
+func (*CondNot) condExpr()     {}
+func (*CondUnary) condExpr()   {}
+func (*CondCompare) condExpr() {}
+func (*CondWord) condExpr()    {}
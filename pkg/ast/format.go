@@ -0,0 +1,293 @@
+package ast
+
+import "strings"
+
+// Format returns shell source for a node that parses back to the same
+// tree. It is used to print function definitions, as declare -f does: each
+// command of a list goes on its own line, and the bodies of compound
+// commands are indented by four spaces.
+func Format(n Node) string {
+	var f formatter
+	f.stmt(n, "")
+	return strings.TrimSuffix(f.b.String(), "\n")
+}
+
+type formatter struct {
+	b     strings.Builder
+	depth int
+}
+
+// line writes one indented line.
+func (f *formatter) line(s string) {
+	f.b.WriteString(strings.Repeat("    ", f.depth))
+	f.b.WriteString(s)
+	f.b.WriteByte('\n')
+}
+
+// list writes the statements of a command list, one per line.
+func (f *formatter) list(n Node) {
+	if b, ok := n.(*BlockNode); ok {
+		for _, s := range b.Statements {
+			f.stmt(s, "")
+		}
+		return
+	}
+	f.stmt(n, "")
+}
+
+// body writes a list one level deeper than the current line.
+func (f *formatter) body(n Node) {
+	f.depth++
+	f.list(n)
+	f.depth--
+}
+
+// stmt writes a statement. Compound commands span several lines; suffix,
+// such as the redirections that apply to all of it, goes after the last.
+func (f *formatter) stmt(n Node, suffix string) {
+	switch n := n.(type) {
+	case *RedirectNode:
+		f.stmt(n.Stmt, redirect(n)+suffix)
+	case *BinaryNode:
+		if n.Operator != "&" {
+			f.line(inline(n) + suffix)
+			return
+		}
+		f.stmt(n.Left, " &")
+		if n.Right != nil {
+			f.stmt(n.Right, suffix)
+		}
+	case *IfNode:
+		f.line("if " + terminated(n.Condition) + " then")
+		f.body(n.Then)
+		for n.Else != nil {
+			elif, ok := n.Else.(*IfNode)
+			if !ok {
+				f.line("else")
+				f.body(n.Else)
+				break
+			}
+			f.line("elif " + terminated(elif.Condition) + " then")
+			f.body(elif.Then)
+			n = elif
+		}
+		f.line("fi" + suffix)
+	case *WhileNode:
+		f.line(whileHeader(n) + " do")
+		f.body(n.Body)
+		f.line("done" + suffix)
+	case *ForNode:
+		f.line(forHeader(n) + "; do")
+		f.body(n.Body)
+		f.line("done" + suffix)
+	case *ArithForNode:
+		f.line(arithForHeader(n) + "; do")
+		f.body(n.Body)
+		f.line("done" + suffix)
+	case *CaseNode:
+		f.line("case " + n.Word.Raw + " in")
+		f.depth++
+		for _, item := range n.Items {
+			f.line(casePatterns(item) + ")")
+			f.depth++
+			f.list(item.Body)
+			f.line(item.Terminator)
+			f.depth--
+		}
+		f.depth--
+		f.line("esac" + suffix)
+	case *FunctionNode:
+		f.line(n.Name + " ()")
+		f.stmt(n.Body, suffix)
+	case *BlockNode:
+		f.line("{")
+		f.body(n)
+		f.line("}" + suffix)
+	case *SubshellNode:
+		f.line("(")
+		f.body(n.Body)
+		f.line(")" + suffix)
+	case nil:
+	default:
+		f.line(inline(n) + suffix)
+	}
+}
+
+// inline returns the source of a command on a single line, for commands
+// inside a pipeline or an && or || list.
+func inline(n Node) string {
+	switch n := n.(type) {
+	case *CommandNode:
+		return n.String()
+	case *PipeNode:
+		var prefix string
+		switch {
+		case n.TimePOSIX:
+			prefix = "time -p "
+		case n.Time:
+			prefix = "time "
+		}
+		if n.Negate {
+			prefix += "! "
+		}
+		var stages []string
+		for _, s := range n.Stages {
+			if s != nil {
+				stages = append(stages, inline(s))
+			}
+		}
+		return strings.TrimSuffix(prefix+strings.Join(stages, " | "), " ")
+	case *RedirectNode:
+		return inline(n.Stmt) + redirect(n)
+	case *BinaryNode:
+		if n.Operator == "&" {
+			if n.Right == nil {
+				return inline(n.Left) + " &"
+			}
+			return inline(n.Left) + " & " + inline(n.Right)
+		}
+		return inline(n.Left) + " " + n.Operator + " " + inline(n.Right)
+	case *IfNode:
+		s := "if " + terminated(n.Condition) + " then " + terminated(n.Then)
+		for n.Else != nil {
+			elif, ok := n.Else.(*IfNode)
+			if !ok {
+				s += " else " + terminated(n.Else)
+				break
+			}
+			s += " elif " + terminated(elif.Condition) + " then " + terminated(elif.Then)
+			n = elif
+		}
+		return s + " fi"
+	case *WhileNode:
+		return whileHeader(n) + " do " + terminated(n.Body) + " done"
+	case *ForNode:
+		return forHeader(n) + "; do " + terminated(n.Body) + " done"
+	case *ArithForNode:
+		return arithForHeader(n) + "; do " + terminated(n.Body) + " done"
+	case *CaseNode:
+		s := "case " + n.Word.Raw + " in"
+		for _, item := range n.Items {
+			s += " " + casePatterns(item) + ")"
+			if body := inlineList(item.Body); body != "" {
+				s += " " + body
+			}
+			s += " " + item.Terminator
+		}
+		return s + " esac"
+	case *FunctionNode:
+		return n.Name + " () " + inline(n.Body)
+	case *BlockNode:
+		return "{ " + terminated(n) + " }"
+	case *SubshellNode:
+		return "( " + inlineList(n.Body) + " )"
+	case *CondNode:
+		return "[[ " + condSource(n.Expr) + " ]]"
+	case nil:
+		return ""
+	}
+	return n.String()
+}
+
+// inlineList returns the source of a command list on a single line. A
+// command run in the background is already ended by its "&".
+func inlineList(n Node) string {
+	b, ok := n.(*BlockNode)
+	if !ok {
+		return inline(n)
+	}
+	var s string
+	for i, stmt := range b.Statements {
+		if i > 0 {
+			if isBackground(b.Statements[i-1]) {
+				s += " "
+			} else {
+				s += "; "
+			}
+		}
+		s += inline(stmt)
+	}
+	return s
+}
+
+// terminated returns the source of a command list followed by ";", as
+// needed before a reserved word such as "then" or "done".
+func terminated(n Node) string {
+	last := n
+	if b, ok := n.(*BlockNode); ok && len(b.Statements) > 0 {
+		last = b.Statements[len(b.Statements)-1]
+	}
+	if isBackground(last) {
+		return inlineList(n)
+	}
+	return inlineList(n) + ";"
+}
+
+// isBackground reports whether n ends with "&".
+func isBackground(n Node) bool {
+	b, ok := n.(*BinaryNode)
+	return ok && b.Operator == "&" && (b.Right == nil || isBackground(b.Right))
+}
+
+func redirect(r *RedirectNode) string {
+	return " " + r.Type + " " + r.Location.Raw
+}
+
+func whileHeader(n *WhileNode) string {
+	if n.Until {
+		return "until " + terminated(n.Condition)
+	}
+	return "while " + terminated(n.Condition)
+}
+
+func forHeader(n *ForNode) string {
+	if n.Words == nil {
+		return "for " + n.Name
+	}
+	s := "for " + n.Name + " in"
+	for _, w := range n.Words {
+		s += " " + w.Raw
+	}
+	return s
+}
+
+func arithForHeader(n *ArithForNode) string {
+	return "for ((" + n.Init + "; " + n.Cond + "; " + n.Step + "))"
+}
+
+func casePatterns(item CaseItem) string {
+	patterns := make([]string, len(item.Patterns))
+	for i, p := range item.Patterns {
+		patterns[i] = p.Raw
+	}
+	return strings.Join(patterns, " | ")
+}
+
+// condSource returns the source of a [[ ]] expression. Parentheses are put
+// back where the tree differs from the default grouping.
+func condSource(e CondExpr) string {
+	switch e := e.(type) {
+	case *CondWord:
+		return e.Word.Raw
+	case *CondUnary:
+		return e.Op + " " + e.Arg.Raw
+	case *CondCompare:
+		return e.Left.Raw + " " + e.Op + " " + e.Right.Raw
+	case *CondNot:
+		if _, ok := e.X.(*CondBinary); ok {
+			return "! ( " + condSource(e.X) + " )"
+		}
+		return "! " + condSource(e.X)
+	case *CondBinary:
+		left := condSource(e.Left)
+		if l, ok := e.Left.(*CondBinary); ok && l.Op != e.Op {
+			left = "( " + left + " )"
+		}
+		right := condSource(e.Right)
+		if _, ok := e.Right.(*CondBinary); ok {
+			right = "( " + right + " )"
+		}
+		return left + " " + e.Op + " " + right
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package ast
+
+// Word is a shell word split into the parts that expand differently. Raw
+// is the word as it was written.
+type Word struct {
+	Raw   string
+	Parts []WordPart
+
+	// Assign is set for an assignment given as an argument to a declaration
+	// builtin, as in "local arr=(a b)". Such words are not split or globbed.
+	Assign *Assignment
+}
+
+func (w *Word) String() string { return w.Raw }
+
+// Lit returns the text of a word that consists only of unquoted literal
+// text, such as a command name that needs no expansion.
+func (w *Word) Lit() (string, bool) {
+	if len(w.Parts) == 0 {
+		return "", true
+	}
+	if len(w.Parts) == 1 {
+		if l, ok := w.Parts[0].(*Literal); ok {
+			return l.Text, true
+		}
+	}
+	return "", false
+}
+
+// WordPart is one part of a Word.
+type WordPart interface {
+	wordPart()
+}
+
+// Literal is unquoted text. Glob characters in it are special.
+type Literal struct {
+	Text string
+}
+
+// SingleQuoted is text that expands to itself: the inside of '...', or a
+// character escaped with a backslash.
+type SingleQuoted struct {
+	Text string
+}
+
+// DoubleQuoted is "...". Its parts are expanded, but the result is not
+// split into fields or globbed.
+type DoubleQuoted struct {
+	Parts []WordPart
+}
+
+// ParamExp is a parameter expansion: $name, $1, $@, or ${Body} when Braced.
+// Body holds everything between the braces, including any operator.
+type ParamExp struct {
+	Body   string
+	Braced bool
+}
+
+// CmdSubst is a command substitution, $(Source) or `Source`.
+type CmdSubst struct {
+	Source    string
+	Backquote bool
+}
+
+// ProcSubst is a process substitution: <(Source), or >(Source) when
+// Output is set. It expands to a path that reads the output of the command,
+// or writes to its input.
+type ProcSubst struct {
+	Source string
+	Output bool
+}
+
+// ArithExp is an arithmetic expansion, $((Expr)).
+type ArithExp struct {
+	Expr string
+}
+
+// Tilde is a tilde prefix of a word: "~" or "~user" for a home directory,
+// or "~+" and "~-" for the current and previous directory. User is the text
+// after the '~'.
+type Tilde struct {
+	User string
+}
+
+func (*Literal) wordPart()      {}
+func (*SingleQuoted) wordPart() {}
+func (*DoubleQuoted) wordPart() {}
+func (*ParamExp) wordPart()     {}
+func (*CmdSubst) wordPart()     {}
+func (*ProcSubst) wordPart()    {}
+func (*ArithExp) wordPart()     {}
+func (*Tilde) wordPart()        {}
+
+// Assignment is an assignment word: "Name=Value", "Name+=Value" when Append
+// is set, "Name[Index]=Value", or "Name=(...)" for an array.
+type Assignment struct {
+	Raw    string
+	Name   string
+	Index  *Word // nil without a subscript
+	Append bool
+	Value  *Word
+
+	IsArray bool
+	Array   []ArrayElem
+}
+
+// ArrayElem is one element of an array assignment: "value", or
+// "[key]=value" when Key is set.
+type ArrayElem struct {
+	Key   *Word
+	Value *Word
+}
+
+func (a *Assignment) String() string { return a.Raw }
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func (r *Registry) registerAliasBuiltins(add func(string, CmdFunc)) {
+	add("alias", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if len(args) > 0 && args[0] == "-p" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			names := make([]string, 0, len(r.Aliases))
+			for name := range r.Aliases {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				r.printAlias(name, stdout)
+			}
+			return nil
+		}
+
+		var status error
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				if _, found := r.Aliases[name]; !found {
+					fmt.Fprintf(stderr, "alias: %s: not found\n", name)
+					status = ExitStatus(1)
+					continue
+				}
+				r.printAlias(name, stdout)
+				continue
+			}
+			if !isAliasName(name) {
+				fmt.Fprintf(stderr, "alias: `%s': invalid alias name\n", name)
+				status = ExitStatus(1)
+				continue
+			}
+			r.Aliases[name] = value
+			r.CmdTrie.Insert(name + " ")
+		}
+		return status
+	})
+
+	add("unalias", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if len(args) > 0 && args[0] == "-a" {
+			for name := range r.Aliases {
+				r.removeAlias(name)
+			}
+			return nil
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, "unalias: usage: unalias [-a] name [name ...]")
+			return ExitStatus(2)
+		}
+
+		var status error
+		for _, name := range args {
+			if _, ok := r.Aliases[name]; !ok {
+				fmt.Fprintf(stderr, "unalias: %s: not found\n", name)
+				status = ExitStatus(1)
+				continue
+			}
+			r.removeAlias(name)
+		}
+		return status
+	})
+}
+
+// removeAlias deletes an alias, and takes it out of completion unless a
+// command of the same name remains.
+func (r *Registry) removeAlias(name string) {
+	delete(r.Aliases, name)
+	if _, ok := r.Builtins[name]; ok {
+		return
+	}
+	if _, ok := r.Functions[name]; ok {
+		return
+	}
+	if _, err := r.LookPath(name); err == nil {
+		return
+	}
+	r.CmdTrie.Remove(name + " ")
+}
+
+// printAlias writes an alias in the form that defines it again.
+func (r *Registry) printAlias(name string, stdout io.Writer) {
+	value := strings.ReplaceAll(r.Aliases[name], "'", `'\''`)
+	fmt.Fprintf(stdout, "alias %s='%s'\n", name, value)
+}
+
+// isAliasName reports whether name can be defined as an alias. It may not
+// contain characters that would end or change the word it replaces.
+func isAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n/$`=\\'\"|&;()<>")
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// initialDir returns the directory the shell starts in. $PWD is kept when
+// it names the working directory, so a path through a symlink survives.
+func initialDir() string {
+	wd, _ := os.Getwd()
+	pwd := os.Getenv("PWD")
+	if filepath.IsAbs(pwd) && pwd == filepath.Clean(pwd) {
+		a, errA := os.Stat(pwd)
+		b, errB := os.Stat(wd)
+		if errA == nil && errB == nil && os.SameFile(a, b) {
+			return pwd
+		}
+	}
+	return wd
+}
+
+func (r *Registry) registerDirBuiltins(add func(string, CmdFunc)) {
+	add("cd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		physical, args, err := parseDirOpts("cd", "cd [-L|-P] [dir]", args, stderr)
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			fmt.Fprintln(stderr, "cd: too many arguments")
+			return ExitStatus(1)
+		}
+
+		var dir string
+		show := false
+		switch {
+		case len(args) == 0:
+			home, _ := r.Vars.Get("HOME")
+			if home == "" {
+				fmt.Fprintln(stderr, "cd: HOME not set")
+				return ExitStatus(1)
+			}
+			dir = home
+		case args[0] == "-":
+			old, _ := r.Vars.Get("OLDPWD")
+			if old == "" {
+				fmt.Fprintln(stderr, "cd: OLDPWD not set")
+				return ExitStatus(1)
+			}
+			dir, show = old, true
+		case args[0] == "":
+			return nil // before CDPATH, which would find "" in its first entry
+		default:
+			dir = args[0]
+			if found, ok := r.searchCDPATH(dir); ok {
+				// Say where we went when CDPATH chose the directory
+				dir, show = found, true
+			}
+		}
+
+		if err := r.Chdir(dir, physical); err != nil {
+			fmt.Fprintf(stderr, "cd: %s: %v\n", dir, err)
+			return ExitStatus(1)
+		}
+		if show {
+			fmt.Fprintln(stdout, r.Dir)
+		}
+		return nil
+	})
+
+	add("pwd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		physical, args, err := parseDirOpts("pwd", "pwd [-LP]", args, stderr)
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			fmt.Fprintln(stderr, "pwd: too many arguments")
+			return ExitStatus(1)
+		}
+		dir := r.Dir
+		if physical {
+			if dir, err = filepath.EvalSymlinks(r.Dir); err != nil {
+				fmt.Fprintf(stderr, "pwd: %v\n", err)
+				return ExitStatus(1)
+			}
+		}
+		fmt.Fprintln(stdout, dir)
+		return nil
+	})
+}
+
+// parseDirOpts reads the -L and -P options of cd and pwd, and reports
+// whether the last one was -P.
+func parseDirOpts(name, usage string, args []string, stderr io.Writer) (bool, []string, error) {
+	physical := false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			return physical, args[1:], nil
+		}
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'L':
+				physical = false
+			case 'P':
+				physical = true
+			default:
+				fmt.Fprintf(stderr, "%s: -%c: invalid option\n", name, c)
+				fmt.Fprintf(stderr, "%s: usage: %s\n", name, usage)
+				return false, nil, ExitStatus(2)
+			}
+		}
+		args = args[1:]
+	}
+	return physical, args, nil
+}
+
+// searchCDPATH looks for a relative directory in the directories of
+// CDPATH. Names starting with "." or ".." are not searched for. It only
+// reports a match found through a non-empty CDPATH entry.
+func (r *Registry) searchCDPATH(dir string) (string, bool) {
+	cdpath, _ := r.Vars.Get("CDPATH")
+	if cdpath == "" || filepath.IsAbs(dir) {
+		return "", false
+	}
+	first, _, _ := strings.Cut(dir, "/")
+	if first == "." || first == ".." {
+		return "", false
+	}
+	for _, base := range filepath.SplitList(cdpath) {
+		if base == "" || base == "." {
+			if info, err := os.Stat(r.Path(dir)); err == nil && info.IsDir() {
+				return "", false
+			}
+			continue
+		}
+		candidate := filepath.Join(base, dir)
+		if info, err := os.Stat(r.Path(candidate)); err == nil && info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// Chdir changes the shell's working directory and updates PWD and OLDPWD.
+// With physical set, symbolic links in the new directory are resolved;
+// otherwise the path is kept as written, with "." and ".." removed from it
+// lexically, so "cd .." leaves a symlinked directory the way it came in.
+// An empty dir changes nothing, as in bash.
+func (r *Registry) Chdir(dir string, physical bool) error {
+	if dir == "" {
+		return nil
+	}
+	target := r.Path(dir)
+	if physical {
+		resolved, err := filepath.EvalSymlinks(target)
+		if err != nil {
+			return dirError(err)
+		}
+		target = resolved
+	} else {
+		target = filepath.Clean(target)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return dirError(err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Not a directory")
+	}
+	if !r.Subshell {
+		if err := os.Chdir(target); err != nil {
+			return dirError(err)
+		}
+	}
+
+	r.Vars.Set("OLDPWD", r.Dir)
+	r.Dir = target
+	r.Vars.Set("PWD", r.Dir)
+	r.setDirStack()
+	return nil
+}
+
+// setPWD sets PWD for a new shell and exports it.
+func (r *Registry) setPWD() {
+	r.Vars.Declare("PWD", false, func(v *vars.Variable) {
+		v.Value, v.Unset, v.Exported = r.Dir, false, true
+	})
+}
+
+// dirError turns an error from the os package into the message a shell
+// prints, such as "No such file or directory".
+func dirError(err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	msg := err.Error()
+	return fmt.Errorf("%s", strings.ToUpper(msg[:1])+msg[1:])
+}
@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+func (r *Registry) registerDirStackBuiltins(add func(string, CmdFunc)) {
+	add("dirs", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		long, perLine, numbered, cleared := false, false, false, false
+		for _, arg := range args {
+			if isStackIndex(arg) {
+				i, ok := r.stackIndex(arg)
+				if !ok {
+					fmt.Fprintf(stderr, "dirs: %s: directory stack index out of range\n", arg)
+					return ExitStatus(1)
+				}
+				dir := r.dirStack()[i]
+				if !long {
+					dir = r.abbreviateHome(dir)
+				}
+				fmt.Fprintln(stdout, dir)
+				return nil
+			}
+			if len(arg) < 2 || arg[0] != '-' {
+				fmt.Fprintf(stderr, "dirs: %s: invalid argument\n", arg)
+				return ExitStatus(1)
+			}
+			for _, c := range arg[1:] {
+				switch c {
+				case 'c':
+					r.DirStack = nil
+					r.setDirStack()
+					cleared = true
+				case 'l':
+					long = true
+				case 'p':
+					perLine = true
+				case 'v':
+					perLine, numbered = true, true
+				default:
+					fmt.Fprintf(stderr, "dirs: -%c: invalid option\n", c)
+					fmt.Fprintln(stderr, "dirs: usage: dirs [-clpv] [+N] [-N]")
+					return ExitStatus(2)
+				}
+			}
+		}
+		if !cleared {
+			r.printDirs(stdout, long, perLine, numbered)
+		}
+		return nil
+	})
+
+	add("pushd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		noChdir := false
+		if len(args) > 0 && args[0] == "-n" {
+			noChdir = true
+			args = args[1:]
+		}
+		if len(args) > 1 {
+			fmt.Fprintln(stderr, "pushd: too many arguments")
+			return ExitStatus(1)
+		}
+
+		stack := r.dirStack()
+		switch {
+		case len(args) == 0 || isStackIndex(args[0]):
+			// Rotate the stack so that the given entry, or the second
+			// one, comes to the top
+			n := 1
+			if len(args) > 0 {
+				var ok bool
+				if n, ok = r.stackIndex(args[0]); !ok {
+					fmt.Fprintf(stderr, "pushd: %s: directory stack index out of range\n", args[0])
+					return ExitStatus(1)
+				}
+			} else if len(stack) < 2 {
+				fmt.Fprintln(stderr, "pushd: no other directory")
+				return ExitStatus(1)
+			}
+			rotated := append(append([]string{}, stack[n:]...), stack[:n]...)
+			if !noChdir {
+				if err := r.Chdir(rotated[0], false); err != nil {
+					fmt.Fprintf(stderr, "pushd: %s: %v\n", rotated[0], err)
+					return ExitStatus(1)
+				}
+			}
+			r.DirStack = rotated[1:]
+
+		case noChdir:
+			// Add the directory below the top without going there
+			r.DirStack = append([]string{r.Path(args[0])}, r.DirStack...)
+
+		default:
+			old := r.Dir
+			if err := r.Chdir(args[0], false); err != nil {
+				fmt.Fprintf(stderr, "pushd: %s: %v\n", args[0], err)
+				return ExitStatus(1)
+			}
+			r.DirStack = append([]string{old}, r.DirStack...)
+		}
+		r.setDirStack()
+		r.printDirs(stdout, false, false, false)
+		return nil
+	})
+
+	add("popd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		noChdir := false
+		if len(args) > 0 && args[0] == "-n" {
+			noChdir = true
+			args = args[1:]
+		}
+		if len(args) > 1 || len(args) == 1 && !isStackIndex(args[0]) {
+			fmt.Fprintln(stderr, "popd: usage: popd [-n] [+N | -N]")
+			return ExitStatus(2)
+		}
+		if len(r.DirStack) == 0 {
+			fmt.Fprintln(stderr, "popd: directory stack empty")
+			return ExitStatus(1)
+		}
+
+		i := 0
+		if len(args) > 0 {
+			var ok bool
+			if i, ok = r.stackIndex(args[0]); !ok {
+				fmt.Fprintf(stderr, "popd: %s: directory stack index out of range\n", args[0])
+				return ExitStatus(1)
+			}
+		}
+
+		if i == 0 {
+			// Removing the top entry goes to the one below it
+			if !noChdir {
+				if err := r.Chdir(r.DirStack[0], false); err != nil {
+					fmt.Fprintf(stderr, "popd: %s: %v\n", r.DirStack[0], err)
+					return ExitStatus(1)
+				}
+			}
+			r.DirStack = r.DirStack[1:]
+		} else {
+			r.DirStack = append(r.DirStack[:i-1:i-1], r.DirStack[i:]...)
+		}
+		r.setDirStack()
+		r.printDirs(stdout, false, false, false)
+		return nil
+	})
+}
+
+// dirStack returns the directory stack, with the current directory first.
+func (r *Registry) dirStack() []string {
+	return append([]string{r.Dir}, r.DirStack...)
+}
+
+// setDirStack updates the DIRSTACK array after the stack or the current
+// directory changed.
+func (r *Registry) setDirStack() {
+	stack := r.dirStack()
+	elems := make([]vars.Element, len(stack))
+	for i, dir := range stack {
+		elems[i] = vars.Element{Value: dir}
+	}
+	r.Vars.SetArray("DIRSTACK", elems, false)
+}
+
+// isStackIndex reports whether arg has the +N or -N form of a stack entry.
+func isStackIndex(arg string) bool {
+	if len(arg) < 2 || arg[0] != '+' && arg[0] != '-' {
+		return false
+	}
+	_, err := strconv.ParseUint(arg[1:], 10, 31)
+	return err == nil
+}
+
+// stackIndex returns the position in the stack of the entry that +N counts
+// from the top (the current directory is +0) or -N counts from the bottom.
+// A plain N counts from the top too.
+func (r *Registry) stackIndex(spec string) (int, bool) {
+	fromBottom := strings.HasPrefix(spec, "-")
+	n, err := strconv.Atoi(strings.TrimLeft(spec, "+-"))
+	size := len(r.DirStack) + 1
+	if err != nil || n >= size {
+		return 0, false
+	}
+	if fromBottom {
+		n = size - 1 - n
+	}
+	return n, true
+}
+
+// printDirs writes the directory stack as dirs does. Unless long is set,
+// the home directory is shown as '~'.
+func (r *Registry) printDirs(stdout io.Writer, long, perLine, numbered bool) {
+	stack := r.dirStack()
+	for i, dir := range stack {
+		if !long {
+			dir = r.abbreviateHome(dir)
+		}
+		switch {
+		case numbered:
+			fmt.Fprintf(stdout, "%2d  %s\n", i, dir)
+		case perLine:
+			fmt.Fprintln(stdout, dir)
+		default:
+			if i > 0 {
+				io.WriteString(stdout, " ")
+			}
+			io.WriteString(stdout, dir)
+		}
+	}
+	if !perLine {
+		fmt.Fprintln(stdout)
+	}
+}
+
+// abbreviateHome replaces the home directory at the start of dir with '~'.
+func (r *Registry) abbreviateHome(dir string) string {
+	home, _ := r.Vars.Get("HOME")
+	home = strings.TrimSuffix(home, "/")
+	switch {
+	case home == "":
+		return dir
+	case dir == home:
+		return "~"
+	case strings.HasPrefix(dir, home+"/"):
+		return "~" + dir[len(home):]
+	}
+	return dir
+}
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// Options are the shell options that set turns on and off.
+type Options struct {
+	Errexit   bool // -e: exit when a command fails
+	Noclobber bool // -C: ">" does not overwrite existing files
+	Noexec    bool // -n: read commands without running them
+	Noglob    bool // -f: no pathname expansion
+	Nounset   bool // -u: expanding an unset parameter is an error
+	Pipefail  bool // a pipeline fails if any of its commands fails
+	Xtrace    bool // -x: print commands before running them
+}
+
+// shellOption describes an option: its long name for "set -o" and the
+// letter for the short form, if it has one.
+type shellOption struct {
+	name   string
+	letter byte
+	field  func(*Options) *bool
+}
+
+// shellOptions lists the options in the order "set -o" prints them.
+var shellOptions = []shellOption{
+	{"errexit", 'e', func(o *Options) *bool { return &o.Errexit }},
+	{"noclobber", 'C', func(o *Options) *bool { return &o.Noclobber }},
+	{"noexec", 'n', func(o *Options) *bool { return &o.Noexec }},
+	{"noglob", 'f', func(o *Options) *bool { return &o.Noglob }},
+	{"nounset", 'u', func(o *Options) *bool { return &o.Nounset }},
+	{"pipefail", 0, func(o *Options) *bool { return &o.Pipefail }},
+	{"xtrace", 'x', func(o *Options) *bool { return &o.Xtrace }},
+}
+
+// option returns the option with a long name, as used by "set -o name".
+func (o *Options) option(name string) (*bool, bool) {
+	for _, opt := range shellOptions {
+		if opt.name == name {
+			return opt.field(o), true
+		}
+	}
+	return nil, false
+}
+
+// letter returns the option for a short flag such as 'e'.
+func (o *Options) letter(c byte) (*bool, bool) {
+	for _, opt := range shellOptions {
+		if opt.letter != 0 && opt.letter == c {
+			return opt.field(o), true
+		}
+	}
+	return nil, false
+}
+
+// Flags returns the letters of the options that are on, as $- expands to.
+// An interactive shell also reports 'i'.
+func (r *Registry) Flags() string {
+	var b strings.Builder
+	for _, opt := range shellOptions {
+		if opt.letter != 0 && *opt.field(&r.Options) {
+			b.WriteByte(opt.letter)
+		}
+	}
+	if r.Interactive {
+		b.WriteByte('i')
+	}
+	return b.String()
+}
+
+func (r *Registry) registerOptionBuiltins(add func(string, CmdFunc)) {
+	add("set", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if len(args) == 0 {
+			r.printAssignments(stdout)
+			return nil
+		}
+
+		for len(args) > 0 {
+			arg := args[0]
+			if arg == "--" {
+				r.Positional = append([]string(nil), args[1:]...)
+				return nil
+			}
+			// "set -" turns off -x and ends the options
+			if arg == "-" {
+				r.Options.Xtrace = false
+				args = args[1:]
+				break
+			}
+			if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+				break
+			}
+			on := arg[0] == '-'
+			args = args[1:]
+
+			for i := 1; i < len(arg); i++ {
+				if arg[i] != 'o' {
+					opt, ok := r.Options.letter(arg[i])
+					if !ok {
+						fmt.Fprintf(stderr, "set: %c%c: invalid option\n", arg[0], arg[i])
+						return ExitStatus(2)
+					}
+					*opt = on
+					continue
+				}
+				// "set -o" without a name lists the options
+				if len(args) == 0 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[0], "+") {
+					r.printOptions(!on, stdout)
+					continue
+				}
+				opt, ok := r.Options.option(args[0])
+				if !ok {
+					fmt.Fprintf(stderr, "set: %s: invalid option name\n", args[0])
+					return ExitStatus(2)
+				}
+				*opt = on
+				args = args[1:]
+			}
+		}
+
+		// Any words left over become the positional parameters
+		if len(args) > 0 {
+			r.Positional = append([]string(nil), args...)
+		}
+		return nil
+	})
+}
+
+// printOptions lists the long options and whether they are on, or with
+// asCommands as the set commands that restore them.
+func (r *Registry) printOptions(asCommands bool, stdout io.Writer) {
+	for _, opt := range shellOptions {
+		on := *opt.field(&r.Options)
+		switch {
+		case asCommands && on:
+			fmt.Fprintf(stdout, "set -o %s\n", opt.name)
+		case asCommands:
+			fmt.Fprintf(stdout, "set +o %s\n", opt.name)
+		case on:
+			fmt.Fprintf(stdout, "%-15s\ton\n", opt.name)
+		default:
+			fmt.Fprintf(stdout, "%-15s\toff\n", opt.name)
+		}
+	}
+}
+
+// printAssignments lists every variable as an assignment that can be read
+// back in, which is what set prints without arguments.
+func (r *Registry) printAssignments(stdout io.Writer) {
+	for _, name := range r.Vars.Names() {
+		v, _ := r.Vars.Var(name)
+		switch {
+		case v.Unset:
+		case v.IsArray():
+			keys, values := v.Keys(), v.Values()
+			elems := make([]vars.Element, len(keys))
+			for i := range keys {
+				elems[i] = vars.Element{Key: keys[i], Keyed: true, Value: values[i]}
+			}
+			fmt.Fprintf(stdout, "%s=%s\n", name, vars.FormatArray(elems))
+		default:
+			fmt.Fprintf(stdout, "%s=%s\n", name, vars.Quote(v.Value))
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func (r *Registry) registerPrintBuiltins(add func(string, CmdFunc)) {
+	add("echo", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		newline, escapes := true, false
+		// Only arguments made of option letters are options, so "echo -x"
+		// prints "-x"
+		for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+			for _, c := range args[0][1:] {
+				switch c {
+				case 'n':
+					newline = false
+				case 'e':
+					escapes = true
+				case 'E':
+					escapes = false
+				}
+			}
+			args = args[1:]
+		}
+
+		out := strings.Join(args, " ")
+		if escapes {
+			var stop bool
+			if out, stop = expandEscapes(out, true); stop {
+				newline = false
+			}
+		}
+		if newline {
+			out += "\n"
+		}
+		io.WriteString(stdout, out)
+		return nil
+	})
+
+	add("printf", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		varName := ""
+		if len(args) > 0 && args[0] == "-v" {
+			if len(args) < 2 {
+				fmt.Fprintln(stderr, "printf: -v: option requires an argument")
+				return ExitStatus(2)
+			}
+			varName = args[1]
+			args = args[2:]
+		}
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, "printf: usage: printf [-v var] format [arguments]")
+			return ExitStatus(2)
+		}
+
+		p := &printer{args: args[1:], stderr: stderr}
+		p.run(args[0])
+
+		if varName != "" {
+			if err := r.Vars.Set(varName, p.out.String()); err != nil {
+				fmt.Fprintf(stderr, "printf: %v\n", err)
+				return ExitStatus(1)
+			}
+		} else {
+			io.WriteString(stdout, p.out.String())
+		}
+		return p.status
+	})
+}
+
+// printer formats the output of printf.
+type printer struct {
+	out    strings.Builder
+	args   []string
+	used   bool // an argument was consumed in this pass over the format
+	stderr io.Writer
+	status error
+	stop   bool // \c in a %b argument ends all output
+}
+
+// run applies the format, again and again while arguments remain.
+func (p *printer) run(format string) {
+	for {
+		p.used = false
+		p.format(format)
+		if p.stop || len(p.args) == 0 || !p.used {
+			return
+		}
+	}
+}
+
+// next returns the next argument, or "" once they run out.
+func (p *printer) next() string {
+	p.used = true
+	if len(p.args) == 0 {
+		return ""
+	}
+	arg := p.args[0]
+	p.args = p.args[1:]
+	return arg
+}
+
+func (p *printer) format(format string) {
+	for i := 0; i < len(format) && !p.stop; i++ {
+		c := format[i]
+		if c == '\\' {
+			text, n := escape(format[i:], false)
+			if text == "\\c" {
+				p.stop = true
+				return
+			}
+			p.out.WriteString(text)
+			i += n - 1
+			continue
+		}
+		if c != '%' {
+			p.out.WriteByte(c)
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			p.out.WriteByte('%')
+			i++
+			continue
+		}
+
+		n, ok := p.conversion(format[i:])
+		if !ok {
+			p.stop = true
+			return
+		}
+		i += n - 1
+	}
+}
+
+// conversion formats one "%..." conversion at the start of spec and
+// returns its length.
+func (p *printer) conversion(spec string) (int, bool) {
+	i := 1
+	flags := ""
+	for i < len(spec) && strings.IndexByte("-+ #0", spec[i]) >= 0 {
+		flags += string(spec[i])
+		i++
+	}
+	width, n := p.number(spec[i:])
+	i += n
+	precision := ""
+	if i < len(spec) && spec[i] == '.' {
+		i++
+		var prec string
+		prec, n = p.number(spec[i:])
+		precision = "." + prec
+		if prec == "" {
+			precision = ".0"
+		}
+		i += n
+	}
+
+	// %(datefmt)T formats a time
+	if i < len(spec) && spec[i] == '(' {
+		end := strings.Index(spec[i:], ")T")
+		if end < 0 {
+			fmt.Fprintf(p.stderr, "printf: `%s': missing time format\n", spec[i:])
+			p.status = ExitStatus(1)
+			return 0, false
+		}
+		datefmt := spec[i+1 : i+end]
+		p.out.WriteString(fmt.Sprintf("%"+flags+width+precision+"s", strftime(datefmt, p.time(p.next()))))
+		return i + end + 2, true
+	}
+
+	if i >= len(spec) {
+		fmt.Fprintf(p.stderr, "printf: `%s': missing format character\n", spec)
+		p.status = ExitStatus(1)
+		return 0, false
+	}
+	verb := spec[i]
+	layout := "%" + flags + width + precision
+
+	switch verb {
+	case 's':
+		p.out.WriteString(fmt.Sprintf(layout+"s", p.next()))
+	case 'b':
+		text, stop := expandEscapes(p.next(), true)
+		p.out.WriteString(fmt.Sprintf(layout+"s", text))
+		p.stop = stop
+	case 'q':
+		p.out.WriteString(fmt.Sprintf(layout+"s", shellQuote(p.next())))
+	case 'c':
+		arg := p.next()
+		if arg != "" {
+			_, size := utf8.DecodeRuneInString(arg)
+			arg = arg[:size]
+		}
+		p.out.WriteString(fmt.Sprintf(layout+"s", arg))
+	case 'd', 'i':
+		p.out.WriteString(fmt.Sprintf(layout+"d", p.integer(p.next())))
+	case 'o', 'u', 'x', 'X':
+		if verb == 'u' {
+			verb = 'd'
+		}
+		p.out.WriteString(fmt.Sprintf(layout+string(verb), uint64(p.integer(p.next()))))
+	case 'f', 'F', 'e', 'E', 'g', 'G', 'a', 'A':
+		switch verb {
+		case 'a':
+			verb = 'x'
+		case 'A':
+			verb = 'X'
+		}
+		p.out.WriteString(fmt.Sprintf(layout+string(verb), p.float(p.next())))
+	default:
+		fmt.Fprintf(p.stderr, "printf: `%c': invalid format character\n", verb)
+		p.status = ExitStatus(1)
+		return 0, false
+	}
+	return i + 1, true
+}
+
+// number reads a width or precision: digits, or '*' to take it from the
+// arguments.
+func (p *printer) number(s string) (string, int) {
+	if s != "" && s[0] == '*' {
+		return strconv.FormatInt(p.integer(p.next()), 10), 1
+	}
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return s[:n], n
+}
+
+// integer converts an argument for a numeric conversion. A leading quote
+// gives the code of the character after it, as in "'A".
+func (p *printer) integer(arg string) int64 {
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(arg[1:])
+		if len(arg) == 1 {
+			r = 0
+		}
+		return int64(r)
+	}
+	s := strings.TrimSpace(arg)
+	if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return int64(n)
+	}
+	fmt.Fprintf(p.stderr, "printf: %s: invalid number\n", arg)
+	p.status = ExitStatus(1)
+	// Use the number the argument starts with, if any
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.ParseInt(s[:end], 10, 64)
+	return n
+}
+
+func (p *printer) float(arg string) float64 {
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		return float64(p.integer(arg))
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+	if err != nil {
+		fmt.Fprintf(p.stderr, "printf: %s: invalid number\n", arg)
+		p.status = ExitStatus(1)
+	}
+	return f
+}
+
+// time converts the argument of %(...)T, in seconds since the epoch. An
+// empty argument or -1 means the current time.
+func (p *printer) time(arg string) time.Time {
+	if arg == "" {
+		return time.Now()
+	}
+	n := p.integer(arg)
+	if n == -1 || n == -2 {
+		return time.Now()
+	}
+	return time.Unix(n, 0)
+}
+
+// expandEscapes replaces the backslash escapes in s, as echo -e and %b do.
+// It reports whether s contained \c, which ends the output there.
+func expandEscapes(s string, echo bool) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		text, n := escape(s[i:], echo)
+		if text == "\\c" {
+			return b.String(), true
+		}
+		b.WriteString(text)
+		i += n - 1
+	}
+	return b.String(), false
+}
+
+// escape decodes the backslash escape at the start of s and returns its
+// text and length. \c is returned as it is for the caller to handle. For
+// echo an octal escape is \0nnn; in a printf format it is \nnn.
+func escape(s string, echo bool) (string, int) {
+	if len(s) < 2 {
+		return s, len(s)
+	}
+	switch c := s[1]; c {
+	case 'a':
+		return "\a", 2
+	case 'b':
+		return "\b", 2
+	case 'e', 'E':
+		return "\x1b", 2
+	case 'f':
+		return "\f", 2
+	case 'n':
+		return "\n", 2
+	case 'r':
+		return "\r", 2
+	case 't':
+		return "\t", 2
+	case 'v':
+		return "\v", 2
+	case '\\':
+		return "\\", 2
+	case 'c':
+		return "\\c", 2
+	case '"':
+		if !echo {
+			return "\"", 2
+		}
+	case 'x':
+		if v, n := digits(s[2:], 16, 2); n > 0 {
+			return string([]byte{byte(v)}), 2 + n
+		}
+	case 'u', 'U':
+		max := 4
+		if c == 'U' {
+			max = 8
+		}
+		if v, n := digits(s[2:], 16, max); n > 0 {
+			return string(rune(v)), 2 + n
+		}
+	default:
+		if c >= '0' && c <= '7' {
+			start := 1
+			if echo {
+				if c != '0' {
+					break
+				}
+				start = 2
+			}
+			v, n := digits(s[start:], 8, 3)
+			return string([]byte{byte(v)}), start + n
+		}
+	}
+	return s[:2], 2
+}
+
+// digits reads up to max digits in base and returns their value and count.
+func digits(s string, base, max int) (int64, int) {
+	n := 0
+	for n < len(s) && n < max {
+		if _, err := strconv.ParseInt(s[n:n+1], base, 64); err != nil {
+			break
+		}
+		n++
+	}
+	v, _ := strconv.ParseInt(s[:n], base, 64)
+	return v, n
+}
+
+// shellQuote quotes s so the shell reads it back as one word, as printf %q
+// does: special characters get a backslash, and control characters make
+// it a $'...' string.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return "$'" + strings.NewReplacer(
+				`\`, `\\`, `'`, `\'`, "\n", `\n`, "\t", `\t`, "\r", `\r`, "\x1b", `\E`,
+			).Replace(s) + "'"
+		}
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if strings.ContainsRune(" \t\"'\\|&;()<>$`!*?[]{}#~=%,^", r) && !(r == '~' && i > 0) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// strftime formats t like the C function of the same name.
+func strftime(format string, t time.Time) string {
+	if format == "" {
+		format = "%X"
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'c':
+			b.WriteString(t.Format("Mon Jan  2 15:04:05 2006"))
+		case 'C':
+			fmt.Fprintf(&b, "%02d", t.Year()/100)
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'D', 'x':
+			b.WriteString(t.Format("01/02/06"))
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'k':
+			fmt.Fprintf(&b, "%2d", t.Hour())
+		case 'l':
+			fmt.Fprintf(&b, "%2d", (t.Hour()+11)%12+1)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'n':
+			b.WriteByte('\n')
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 't':
+			b.WriteByte('\t')
+		case 'T', 'X':
+			b.WriteString(t.Format("15:04:05"))
+		case 'u':
+			fmt.Fprintf(&b, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(&b, "%d", t.Year())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/term"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// readOpts are the options of the read builtin.
+type readOpts struct {
+	raw     bool   // -r: backslash is not an escape character
+	silent  bool   // -s: do not echo input from a terminal
+	prompt  string // -p
+	array   string // -a: store the fields in this array
+	delim   byte   // -d: end of input instead of newline
+	count   int    // -n: stop after this many characters, if > 0
+	timeout time.Duration
+	timed   bool // -t was given
+}
+
+// errReadTimeout is returned when read -t runs out of time.
+var errReadTimeout = errors.New("read timed out")
+
+func (r *Registry) registerReadBuiltins(add func(string, CmdFunc)) {
+	add("read", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		opts, names, err := parseReadOpts(args, stderr)
+		if err != nil {
+			return err
+		}
+		for _, name := range append(names, opts.array) {
+			if name != "" && !vars.IsName(name) {
+				fmt.Fprintf(stderr, "read: `%s': not a valid identifier\n", name)
+				return ExitStatus(1)
+			}
+		}
+
+		file, _ := stdin.(*os.File)
+		tty := file != nil && term.IsTerminal(fileFd(file))
+		if tty {
+			if opts.prompt != "" {
+				fmt.Fprint(stderr, opts.prompt)
+			}
+			// -n and -d need each byte as it is typed, not whole lines
+			canonical := opts.count == 0 && opts.delim == '\n'
+			if old, err := term.SetInputMode(fileFd(file), canonical, !opts.silent); err == nil {
+				defer term.RestoreTerminal(fileFd(file), old)
+			}
+		}
+
+		if opts.timed && opts.timeout == 0 {
+			// -t 0 only checks whether there is input to read
+			if file == nil || inputReady(file, time.Now()) {
+				return nil
+			}
+			return ExitStatus(1)
+		}
+		var deadline time.Time
+		if opts.timed {
+			deadline = time.Now().Add(opts.timeout)
+		}
+
+		line, escaped, err := readInput(stdin, file, opts, deadline)
+		if tty && opts.silent && opts.delim == '\n' {
+			fmt.Fprintln(stderr)
+		}
+
+		var assignErr error
+		switch {
+		case opts.array != "":
+			fields := splitRead(line, escaped, r.ifs(), -1)
+			elems := make([]vars.Element, len(fields))
+			for i, f := range fields {
+				elems[i] = vars.Element{Value: f}
+			}
+			assignErr = r.Vars.SetArray(opts.array, elems, false)
+		case len(names) == 0:
+			// REPLY gets the line as it was, without trimming
+			assignErr = r.Vars.Set("REPLY", string(line))
+		default:
+			fields := splitRead(line, escaped, r.ifs(), len(names))
+			for i, name := range names {
+				value := ""
+				if i < len(fields) {
+					value = fields[i]
+				}
+				if assignErr = r.Vars.Set(name, value); assignErr != nil {
+					break
+				}
+			}
+		}
+		if assignErr != nil {
+			fmt.Fprintf(stderr, "read: %v\n", assignErr)
+			return ExitStatus(1)
+		}
+
+		switch {
+		case err == errReadTimeout:
+			return ExitStatus(142) // 128 + SIGALRM, like bash
+		case err != nil:
+			return ExitStatus(1)
+		}
+		return nil
+	})
+}
+
+func parseReadOpts(args []string, stderr io.Writer) (readOpts, []string, error) {
+	opts := readOpts{delim: '\n'}
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			c := arg[i]
+			switch c {
+			case 'r':
+				opts.raw = true
+				continue
+			case 's':
+				opts.silent = true
+				continue
+			case 'p', 'a', 'd', 'n', 't':
+			default:
+				fmt.Fprintf(stderr, "read: -%c: invalid option\n", c)
+				fmt.Fprintln(stderr, "read: usage: read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [name ...]")
+				return opts, nil, ExitStatus(2)
+			}
+
+			// The option's value is the rest of this argument or the next one
+			value := arg[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					fmt.Fprintf(stderr, "read: -%c: option requires an argument\n", c)
+					return opts, nil, ExitStatus(2)
+				}
+				value = args[0]
+				args = args[1:]
+			}
+			switch c {
+			case 'p':
+				opts.prompt = value
+			case 'a':
+				opts.array = value
+			case 'd':
+				opts.delim = 0
+				if value != "" {
+					opts.delim = value[0]
+				}
+			case 'n':
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					fmt.Fprintf(stderr, "read: %s: invalid number\n", value)
+					return opts, nil, ExitStatus(1)
+				}
+				opts.count = n
+			case 't':
+				secs, err := strconv.ParseFloat(value, 64)
+				if err != nil || secs < 0 {
+					fmt.Fprintf(stderr, "read: %s: invalid timeout specification\n", value)
+					return opts, nil, ExitStatus(1)
+				}
+				opts.timeout = time.Duration(secs * float64(time.Second))
+				opts.timed = true
+			}
+			break
+		}
+	}
+	return opts, args, nil
+}
+
+// readInput reads up to the delimiter, which it drops. Unless opts.raw is
+// set, a backslash escapes the next character, and a backslash before a
+// newline joins the lines. escaped marks the characters that were escaped,
+// which are not split into fields.
+func readInput(stdin io.Reader, file *os.File, opts readOpts, deadline time.Time) (line []byte, escaped []bool, err error) {
+	// Pipes support deadlines; other files are polled before each read
+	var poll *os.File
+	if file != nil && !deadline.IsZero() {
+		if file.SetReadDeadline(deadline) == nil {
+			defer file.SetReadDeadline(time.Time{})
+		} else {
+			poll = file
+		}
+	}
+
+	chars := 0
+	for opts.count == 0 || chars < opts.count {
+		c, err := readByte(stdin, poll, deadline)
+		if err != nil {
+			return line, escaped, err
+		}
+		if c == opts.delim {
+			return line, escaped, nil
+		}
+
+		isEscaped := false
+		if c == '\\' && !opts.raw {
+			if c, err = readByte(stdin, poll, deadline); err != nil {
+				return line, escaped, err
+			}
+			if c == '\n' {
+				continue
+			}
+			isEscaped = true
+		}
+
+		// Count characters, not bytes
+		if utf8.RuneStart(c) {
+			chars++
+		}
+		line = append(line, c)
+		escaped = append(escaped, isEscaped)
+		for n := utf8RuneLen(c) - 1; n > 0; n-- {
+			if c, err = readByte(stdin, poll, deadline); err != nil {
+				return line, escaped, err
+			}
+			line = append(line, c)
+			escaped = append(escaped, isEscaped)
+		}
+	}
+	return line, escaped, nil
+}
+
+// readByte reads a single byte, so that read never takes input meant for
+// the commands after it. If poll is set, it waits for input on it until
+// the deadline first. The deadline is also checked before every byte, as
+// input that is always ready, like /dev/zero, never makes a read wait.
+func readByte(stdin io.Reader, poll *os.File, deadline time.Time) (byte, error) {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return 0, errReadTimeout
+	}
+	if poll != nil && !inputReady(poll, deadline) {
+		return 0, errReadTimeout
+	}
+	var b [1]byte
+	for {
+		n, err := stdin.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return 0, errReadTimeout
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// inputReady waits until f has input to read or the deadline passes. A
+// descriptor that select cannot watch is reported as ready, so the read
+// blocks instead.
+func inputReady(f *os.File, deadline time.Time) bool {
+	fd := fileFd(f)
+	if fd < 0 || fd >= syscall.FD_SETSIZE {
+		return true
+	}
+	for {
+		wait := time.Until(deadline)
+		if wait < 0 {
+			wait = 0
+		}
+		tv := syscall.NsecToTimeval(wait.Nanoseconds())
+		var set syscall.FdSet
+		set.Bits[fd/64] |= 1 << (uint(fd) % 64)
+		n, err := syscall.Select(fd+1, &set, nil, nil, &tv)
+		if err == syscall.EINTR {
+			continue
+		}
+		return err != nil || n > 0
+	}
+}
+
+// fileFd returns the descriptor of f. Unlike f.Fd it leaves a pipe in
+// non-blocking mode, so deadlines keep working for it.
+func fileFd(f *os.File) int {
+	fd := -1
+	if conn, err := f.SyscallConn(); err == nil {
+		conn.Control(func(u uintptr) { fd = int(u) })
+	}
+	return fd
+}
+
+// utf8RuneLen returns the length of the UTF-8 sequence that starts with c.
+func utf8RuneLen(c byte) int {
+	switch {
+	case c >= 0xF0:
+		return 4
+	case c >= 0xE0:
+		return 3
+	case c >= 0xC0:
+		return 2
+	}
+	return 1
+}
+
+// ifs returns the value of IFS, or its default when it is unset.
+func (r *Registry) ifs() string {
+	if ifs, ok := r.Vars.Get("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+// splitRead splits a line read by read into at most n fields (all of them
+// if n < 0). IFS whitespace around fields is dropped, and any other IFS
+// character separates two fields. The last field gets the rest of the line,
+// separators included.
+func splitRead(line []byte, escaped []bool, ifs string, n int) []string {
+	isSep := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+	}
+	isSpace := func(i int) bool {
+		return isSep(i) && strings.IndexByte(" \t\n", line[i]) >= 0
+	}
+
+	var fields []string
+	i := 0
+	for i < len(line) && isSpace(i) {
+		i++
+	}
+	for i < len(line) {
+		if n > 0 && len(fields) == n-1 {
+			// The last field: the rest without trailing IFS whitespace
+			end := len(line)
+			for end > i && isSpace(end-1) {
+				end--
+			}
+			fields = append(fields, string(line[i:end]))
+			break
+		}
+		start := i
+		for i < len(line) && !isSep(i) {
+			i++
+		}
+		fields = append(fields, string(line[start:i]))
+
+		// Skip the separator: IFS whitespace around at most one other
+		// IFS character
+		for i < len(line) && isSpace(i) {
+			i++
+		}
+		if i < len(line) && isSep(i) {
+			i++
+			for i < len(line) && isSpace(i) {
+				i++
+			}
+		}
+	}
+	return fields
+}
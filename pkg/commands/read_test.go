@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// endless is input that is always ready and never ends.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func TestReadTimeoutOnEndlessInput(t *testing.T) {
+	zero, err := os.Open("/dev/zero")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer zero.Close()
+
+	for name, stdin := range map[string]io.Reader{"reader": endless{}, "/dev/zero": zero} {
+		r := NewRegistry()
+		done := make(chan error, 1)
+		go func() {
+			done <- r.Builtins["read"]([]string{"-t", "0.1", "x"}, stdin, io.Discard, io.Discard)
+		}()
+
+		select {
+		case err := <-done:
+			var status ExitStatus
+			if !errors.As(err, &status) || status != 142 {
+				t.Errorf("%s: read -t 0.1 returned %v, want status 142", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: read -t 0.1 did not time out", name)
+		}
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sort"
 	"syscall"
+	"github.com/codecrafters-io/shell-starter-go/pkg/arith"
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
 	"github.com/codecrafters-io/shell-starter-go/pkg/history"
 	"github.com/codecrafters-io/shell-starter-go/pkg/utils"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
 
 )
 
-type CmdFunc func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer)
+type CmdFunc func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
 type TrieNode struct {
 	children map[rune]*TrieNode
 	isEnd    bool
@@ -41,6 +46,17 @@ func (t *Trie) Insert(word string) {
 	node.word = word
 }
 
+// Remove deletes a word inserted earlier.
+func (t *Trie) Remove(word string) {
+	node := t.root
+	for _, ch := range word {
+		if node = node.children[ch]; node == nil {
+			return
+		}
+	}
+	node.isEnd = false
+}
+
 func (t *Trie) SearchPrefix(prefix string) []string {
 	node := t.root
 	for _, ch := range prefix {
@@ -74,27 +90,134 @@ type Job struct {
 
 type Registry struct {
 	Builtins   map[string]CmdFunc
+	Functions  map[string]*ast.FunctionNode
+	Aliases    map[string]string
 	CmdTrie    *Trie
 	History    *history.HistoryStruct
 	ExitSignal bool
+	ExitCode   int // status passed to exit
+
+	// Dir is the shell's working directory. Only the top-level shell moves
+	// the process itself; subshells run concurrently in the same process, so
+	// they resolve relative paths against Dir instead (see Path).
+	Dir      string
+	DirStack []string // pushd's saved directories, below Dir
+	Subshell bool
+
+	// Interactive is set when the shell reads commands from a terminal.
+	Interactive bool
+
+	Vars        *vars.Store
+	LastStatus  int // $?
+	LoopDepth   int // number of loops currently executing, for break/continue
+	FuncDepth   int // number of function calls currently executing
+	SourceDepth int // number of sourced scripts currently executing
+	CondDepth   int // number of conditions being tested, where set -e does not apply
+
+	Name       string   // $0: the name of the shell, or of the script it runs
+	Positional []string // $1, $2, ...
+	Options    Options
 
 	Jobs      map[int]*Job
 	JobMutex  sync.Mutex
+
+	// ProcFiles are the pipes of the process substitutions in use, by
+	// descriptor. External commands get them, as they would inherit them
+	// from a shell process.
+	ProcFiles map[int]*os.File
 }
 
 func NewRegistry() *Registry {
 	r := &Registry{
 		Builtins: make(map[string]CmdFunc),
+		Functions: make(map[string]*ast.FunctionNode),
+		Aliases:   make(map[string]string),
 		CmdTrie:  NewTrie(),
 		History:  &history.HistoryStruct{},
 		Jobs:     make(map[int]*Job),
+		Vars:     vars.FromEnviron(),
+		ProcFiles: make(map[int]*os.File),
 	}
+	r.Dir = initialDir()
+	r.setPWD()
+	r.setDirStack()
+	r.Name = os.Args[0]
 	r.registerBuiltins()
 	r.loadPathExecutables()
 
 	return r
 }
 
+// NewSubshell returns a copy of the shell state for running a subshell.
+// Changes the subshell makes to variables, functions, aliases or the working
+// directory do not affect r. The subshell has its own completion trie, since
+// subshells in a pipeline run concurrently with each other and with r.
+func (r *Registry) NewSubshell() *Registry {
+	s := &Registry{
+		Builtins:    make(map[string]CmdFunc),
+		Functions:   make(map[string]*ast.FunctionNode, len(r.Functions)),
+		Aliases:     make(map[string]string, len(r.Aliases)),
+		CmdTrie:     NewTrie(),
+		History:     r.History,
+		Jobs:        make(map[int]*Job),
+		Vars:        r.Vars.Clone(),
+		LastStatus:  r.LastStatus,
+		LoopDepth:   r.LoopDepth,
+		FuncDepth:   r.FuncDepth,
+		SourceDepth: r.SourceDepth,
+		CondDepth:   r.CondDepth,
+		Name:        r.Name,
+		Positional:  append([]string(nil), r.Positional...),
+		Options:     r.Options,
+		Dir:         r.Dir,
+		DirStack:    append([]string(nil), r.DirStack...),
+		Subshell:    true,
+		Interactive: r.Interactive,
+		ProcFiles:   make(map[int]*os.File, len(r.ProcFiles)),
+	}
+	for name, fn := range r.Functions {
+		s.Functions[name] = fn
+	}
+	for name, value := range r.Aliases {
+		s.Aliases[name] = value
+	}
+	for fd, f := range r.ProcFiles {
+		s.ProcFiles[fd] = f
+	}
+	s.registerBuiltins()
+	return s
+}
+
+// LookPath searches the shell's PATH for an executable, like exec.LookPath
+// but using the shell's variables and working directory. Names containing a
+// slash are used as they are.
+func (r *Registry) LookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return exec.LookPath(r.Path(name))
+	}
+	pathEnv, _ := r.Vars.Get("PATH")
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			dir = "."
+		}
+		if path, err := exec.LookPath(r.Path(filepath.Join(dir, name))); err == nil {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// Path resolves a path given to the shell against its working directory.
+func (r *Registry) Path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if strings.HasSuffix(r.Dir, "/") {
+		return r.Dir + p
+	}
+	return r.Dir + "/" + p
+}
+
 func (r *Registry) loadPathExecutables() {
 	pathEnv := os.Getenv("PATH")
 	paths := strings.Split(pathEnv, string(os.PathListSeparator))
@@ -125,6 +248,28 @@ func (r *Registry) loadPathExecutables() {
 	}
 }
 
+// DefineFunction stores a shell function and offers it for completion.
+func (r *Registry) DefineFunction(fn *ast.FunctionNode) {
+	r.Functions[fn.Name] = fn
+	r.CmdTrie.Insert(fn.Name + " ")
+}
+
+// UndefineFunction deletes a shell function, and takes it out of completion
+// unless a command of the same name remains.
+func (r *Registry) UndefineFunction(name string) {
+	delete(r.Functions, name)
+	if _, ok := r.Builtins[name]; ok {
+		return
+	}
+	if _, ok := r.Aliases[name]; ok {
+		return
+	}
+	if _, err := r.LookPath(name); err == nil {
+		return
+	}
+	r.CmdTrie.Remove(name + " ")
+}
+
 func (r *Registry) Suggest(prefix string) ([]string, bool) {
 	candidates := r.CmdTrie.SearchPrefix(prefix)
 
@@ -141,39 +286,43 @@ func (r *Registry) registerBuiltins() {
 		r.CmdTrie.Insert(name + " ")
 	}
 
-	add("exit", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	add("exit", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		r.ExitCode = r.LastStatus
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(stderr, "exit: %s: numeric argument required\n", args[0])
+				n = 2
+			}
+			r.ExitCode = n & 0xff
+		}
 		r.ExitSignal = true
+		return nil
 	})
 
-	add("echo", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
-		fmt.Fprintln(stdout, strings.Join(args, " "))
-	})
 
-	add("type", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	add("type", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		if len(args) == 0 {
 			fmt.Fprintln(stderr, "type: missing operand")
-			return
+			return ExitStatus(1)
 		}
 		cmd := args[0]
-		if _, ok := r.Builtins[cmd]; ok {
+		if value, ok := r.Aliases[cmd]; ok {
+			fmt.Fprintf(stdout, "%s is aliased to `%s'\n", cmd, value)
+		} else if _, ok := r.Functions[cmd]; ok {
+			fmt.Fprintf(stdout, "%s is a function\n", cmd)
+		} else if _, ok := r.Builtins[cmd]; ok {
 			fmt.Fprintf(stdout, "%s is a shell builtin\n", cmd)
-		} else if execPath, err := exec.LookPath(cmd); err == nil {
+		} else if execPath, err := r.LookPath(cmd); err == nil {
 			fmt.Fprintf(stdout, "%s is %s\n", cmd, execPath)
 		} else {
 			fmt.Fprintf(stderr, "%s: not found\n", cmd)
+			return ExitStatus(1)
 		}
+		return nil
 	})
 
-	add("pwd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
-		dir, err := os.Getwd()
-		if err != nil {
-			fmt.Fprintln(stderr, err)
-			return
-		}
-		fmt.Fprintln(stdout, dir)
-	})
-
-	add("ls", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	add("ls", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		dir := "."
 		if len(args) > 0 {
 			dir = args[0]
@@ -186,49 +335,19 @@ func (r *Registry) registerBuiltins() {
 			}
 		}
 
-		files, err := os.ReadDir(dir)
+		files, err := os.ReadDir(r.Path(dir))
 		if err != nil {
 			fmt.Fprintf(stderr, "ls: %s: No such file or directory\n", dir)
-			return
+			return ExitStatus(2)
 		}
 
 		for _, file := range files {
 			fmt.Fprintln(stdout, file.Name()) // Writes to pipe if connected
 		}
+		return nil
 	})
 
-	add("cd", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
-		if len(args) == 0 {
-			fmt.Fprintln(stderr, "cd: missing argument")
-			return
-		}
-
-		dir := args[0]
-		if dir[0] == '~' {
-			if homeDir, er := os.UserHomeDir(); er == nil {
-				dir = strings.Replace(dir, "~", homeDir, 1)
-			} else {
-				fmt.Fprintln(stdout, er)
-			}
-		}
-
-		info, err := os.Stat(dir)
-		if err != nil {
-			fmt.Fprintf(stderr, "cd: %s: No such file or directory\n", dir)
-			return
-		}
-
-		if !info.IsDir() {
-			fmt.Fprintf(stderr, "cd: %s: Not a directory\n", dir)
-			return
-		}
-
-		if err := os.Chdir(dir); err != nil {
-			fmt.Fprintln(stderr, err)
-		}
-	})
-
-	add("history", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	add("history", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		if len(args) > 0 {
 
 			arg := args[0]
@@ -242,20 +361,21 @@ func (r *Registry) registerBuiltins() {
 				case "-a":
 					r.History.AppendNew(path, stderr)
 				}
-				return
+				return nil
 			}
 			r.History.ReadHistory(arg, stdout, stderr)
 		} else {
 			r.History.ReadHistory("", stdout, stderr)
 		}
+		return nil
 	})
 
-	add("jobs" , func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer){
+	add("jobs" , func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		// ReapJobs prints ALL jobs (Running+Done) when any are done, then removes Done ones.
 		// If nothing is done, we fall through and print Running jobs ourselves.
 		hadDone := r.reapJobsLocked(stdout,false)
 		if hadDone {
-			return
+			return nil
 		}
 
 		r.JobMutex.Lock()
@@ -272,10 +392,119 @@ func (r *Registry) registerBuiltins() {
 			sign := utils.MarkerForIndex(i, len(ids))
 			fmt.Fprintf(stdout, "[%d]%s  Running                 %s &\n", job.ID, sign, job.Command)
 		}
+		return nil
+	})
+
+	add(":", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		return nil
+	})
+
+	add("true", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		return nil
+	})
+
+	add("false", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		return ExitStatus(1)
+	})
+
+	add("let", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, "let: expression expected")
+			return ExitStatus(1)
+		}
+		// Each argument is an expression; the status comes from the last one
+		var v int64
+		for _, expr := range args {
+			var err error
+			if v, err = arith.Eval(expr, r.Vars); err != nil {
+				fmt.Fprintf(stderr, "let: %s: %v\n", expr, err)
+				return ExitStatus(1)
+			}
+		}
+		if v == 0 {
+			return ExitStatus(1)
+		}
+		return nil
+	})
+
+	r.registerVariableBuiltins(add)
+	r.registerOptionBuiltins(add)
+	r.registerSourceBuiltins(add)
+	r.registerAliasBuiltins(add)
+	r.registerTestBuiltins(add)
+	r.registerReadBuiltins(add)
+	r.registerPrintBuiltins(add)
+	r.registerDirBuiltins(add)
+	r.registerDirStackBuiltins(add)
+
+	add("break", r.loopControl("break", false))
+	add("continue", r.loopControl("continue", true))
+
+	add("return", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if r.FuncDepth == 0 && r.SourceDepth == 0 {
+			fmt.Fprintln(stderr, "return: can only `return' from a function or sourced script")
+			return ExitStatus(1)
+		}
+		status := r.LastStatus
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(stderr, "return: %s: numeric argument required\n", args[0])
+				n = 2
+			}
+			status = n & 0xff
+		}
+		return &FunctionReturn{Status: status}
+	})
+
+	add("shift", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		n := 1
+		if len(args) > 0 {
+			v, err := strconv.Atoi(args[0])
+			if err != nil || v < 0 {
+				fmt.Fprintf(stderr, "shift: %s: numeric argument required\n", args[0])
+				return ExitStatus(1)
+			}
+			n = v
+		}
+		if n > len(r.Positional) {
+			return ExitStatus(1)
+		}
+		r.Positional = r.Positional[n:]
+		return nil
 	})
 
 }
 
+// loopControl builds the break and continue builtins. "break n" leaves n
+// enclosing loops; asking for more loops than exist leaves all of them.
+func (r *Registry) loopControl(name string, cont bool) CmdFunc {
+	return func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		levels := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %s: numeric argument required\n", name, args[0])
+				return ExitStatus(128)
+			}
+			if n < 1 {
+				fmt.Fprintf(stderr, "%s: %d: loop count out of range\n", name, n)
+				return ExitStatus(1)
+			}
+			levels = n
+		}
+
+		if r.LoopDepth == 0 {
+			fmt.Fprintf(stderr, "%s: only meaningful in a `for', `while', or `until' loop\n", name)
+			return nil
+		}
+		if levels > r.LoopDepth {
+			levels = r.LoopDepth
+		}
+		return &LoopControl{Continue: cont, Levels: levels}
+	}
+}
+
 func (r *Registry) SuggestFilename(token string) ([]string, bool) {
 	var searchDir, prefix string
 	var isLocal bool
@@ -290,7 +519,19 @@ func (r *Registry) SuggestFilename(token string) ([]string, bool) {
 		isLocal = true
 	}
 
-	entries, err := os.ReadDir(searchDir)
+	// Complete "~/" and "~user/" in the home directory, keeping the tilde
+	// in the suggestions
+	readDir := searchDir
+	if strings.HasPrefix(searchDir, "~") {
+		name, rest, _ := strings.Cut(searchDir[1:], "/")
+		home, ok := r.TildeDir(name)
+		if !ok {
+			return nil, false
+		}
+		readDir = home + "/" + rest
+	}
+
+	entries, err := os.ReadDir(readDir)
 	if err != nil {
 		return nil, false
 	}
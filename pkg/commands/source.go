@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScriptRunner runs the commands read from src in r. The executor sets it,
+// as this package cannot import the executor. It returns a *FunctionReturn
+// when the script ends with return.
+var ScriptRunner func(src io.Reader, name string, r *Registry, stdin io.Reader, stdout, stderr io.Writer) error
+
+func (r *Registry) registerSourceBuiltins(add func(string, CmdFunc)) {
+	source := func(name string) CmdFunc {
+		return func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+			if len(args) > 0 && args[0] == "--" {
+				args = args[1:]
+			}
+			if len(args) == 0 {
+				fmt.Fprintf(stderr, "%s: filename argument required\n", name)
+				return ExitStatus(2)
+			}
+			return r.Source(args[0], args[1:], stdin, stdout, stderr)
+		}
+	}
+	add("source", source("source"))
+	add(".", source("."))
+}
+
+// Source runs a script in the current shell, so the variables, functions
+// and directory it sets remain afterwards. If args are given they are the
+// positional parameters while it runs. A name without a slash is looked up
+// in PATH, then in the working directory.
+func (r *Registry) Source(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f, err := os.Open(r.sourcePath(name))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: No such file or directory\n", name)
+		return ExitStatus(1)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		fmt.Fprintf(stderr, "%s: is a directory\n", name)
+		return ExitStatus(1)
+	}
+
+	if len(args) > 0 {
+		saved := r.Positional
+		r.Positional = args
+		defer func() { r.Positional = saved }()
+	}
+	r.SourceDepth++
+	defer func() { r.SourceDepth-- }()
+
+	err = ScriptRunner(f, name, r, stdin, stdout, stderr)
+	if ret, ok := err.(*FunctionReturn); ok {
+		if ret.Status == 0 {
+			return nil
+		}
+		return ExitStatus(ret.Status)
+	}
+	if err == nil && r.LastStatus != 0 {
+		return ExitStatus(r.LastStatus)
+	}
+	return err
+}
+
+// sourcePath finds the file source reads for name.
+func (r *Registry) sourcePath(name string) string {
+	if strings.Contains(name, "/") {
+		return r.Path(name)
+	}
+	pathEnv, _ := r.Vars.Get("PATH")
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			dir = "."
+		}
+		path := r.Path(filepath.Join(dir, name))
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return r.Path(name)
+}
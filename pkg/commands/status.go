@@ -0,0 +1,34 @@
+package commands
+
+import "fmt"
+
+// ExitStatus is the error a builtin returns to report a non-zero exit status.
+type ExitStatus int
+
+func (e ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// LoopControl is returned by the break and continue builtins. It unwinds the
+// executor up to the loop it targets.
+type LoopControl struct {
+	Continue bool
+	Levels   int // number of enclosing loops to leave, at least 1
+}
+
+func (l *LoopControl) Error() string {
+	if l.Continue {
+		return "continue"
+	}
+	return "break"
+}
+
+// FunctionReturn is returned by the return builtin. It unwinds the executor
+// up to the function call, which then exits with Status.
+type FunctionReturn struct {
+	Status int
+}
+
+func (f *FunctionReturn) Error() string {
+	return "return"
+}
@@ -0,0 +1,340 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/term"
+)
+
+// unaryTests are the operators UnaryTest understands.
+const unaryTests = "-a -b -c -d -e -f -g -h -k -n -o -p -r -s -t -u -v -w -x -z -G -L -N -O -S"
+
+// binaryTests are the operators BinaryTest understands.
+const binaryTests = "= == != < > -eq -ne -lt -le -gt -ge -nt -ot -ef"
+
+// IsUnaryTest reports whether op is a unary test operator such as "-f".
+func IsUnaryTest(op string) bool {
+	return len(op) == 2 && op[0] == '-' && strings.Contains(unaryTests, op)
+}
+
+// IsBinaryTest reports whether op is a binary test operator such as "-eq".
+func IsBinaryTest(op string) bool {
+	for _, t := range strings.Fields(binaryTests) {
+		if t == op {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryTest evaluates a unary test, as used by test and [[ ]]. File
+// operands are relative to the shell's working directory.
+func (r *Registry) UnaryTest(op, arg string) bool {
+	switch op {
+	case "-n":
+		return arg != ""
+	case "-z":
+		return arg == ""
+	case "-v":
+		_, ok := r.Vars.Get(arg)
+		return ok
+	case "-o":
+		on, ok := r.Options.option(arg)
+		return ok && *on
+	case "-t":
+		fd, err := strconv.Atoi(strings.TrimSpace(arg))
+		return err == nil && term.IsTerminal(fd)
+	case "-h", "-L":
+		info, err := os.Lstat(r.Path(arg))
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	case "-r":
+		return syscall.Access(r.Path(arg), 4) == nil
+	case "-w":
+		return syscall.Access(r.Path(arg), 2) == nil
+	case "-x":
+		return syscall.Access(r.Path(arg), 1) == nil
+	}
+
+	info, err := os.Stat(r.Path(arg))
+	if err != nil {
+		return false
+	}
+	mode := info.Mode()
+	switch op {
+	case "-a", "-e":
+		return true
+	case "-f":
+		return mode.IsRegular()
+	case "-d":
+		return mode.IsDir()
+	case "-s":
+		return info.Size() > 0
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case "-c":
+		return mode&os.ModeCharDevice != 0
+	case "-p":
+		return mode&os.ModeNamedPipe != 0
+	case "-S":
+		return mode&os.ModeSocket != 0
+	case "-g":
+		return mode&os.ModeSetgid != 0
+	case "-u":
+		return mode&os.ModeSetuid != 0
+	case "-k":
+		return mode&os.ModeSticky != 0
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "-O":
+		return int(st.Uid) == os.Geteuid()
+	case "-G":
+		return int(st.Gid) == os.Getegid()
+	case "-N":
+		return st.Mtim.Nano() > st.Atim.Nano()
+	}
+	return false
+}
+
+// BinaryTest evaluates a binary test, as used by test and [[ ]]. "=" and
+// "==" compare strings here; [[ ]] matches patterns itself.
+func (r *Registry) BinaryTest(op, a, b string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	case "<":
+		return a < b, nil
+	case ">":
+		return a > b, nil
+	case "-nt", "-ot":
+		ia, errA := os.Stat(r.Path(a))
+		ib, errB := os.Stat(r.Path(b))
+		if op == "-ot" {
+			ia, ib, errA, errB = ib, ia, errB, errA
+		}
+		// A file that exists is newer than one that does not
+		if errA != nil {
+			return false, nil
+		}
+		return errB != nil || ia.ModTime().After(ib.ModTime()), nil
+	case "-ef":
+		ia, errA := os.Stat(r.Path(a))
+		ib, errB := os.Stat(r.Path(b))
+		return errA == nil && errB == nil && os.SameFile(ia, ib), nil
+	}
+
+	x, err := testInteger(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := testInteger(b)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case "-eq":
+		return x == y, nil
+	case "-ne":
+		return x != y, nil
+	case "-lt":
+		return x < y, nil
+	case "-le":
+		return x <= y, nil
+	case "-gt":
+		return x > y, nil
+	case "-ge":
+		return x >= y, nil
+	}
+	return false, fmt.Errorf("%s: binary operator expected", op)
+}
+
+func testInteger(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
+
+func (r *Registry) registerTestBuiltins(add func(string, CmdFunc)) {
+	test := func(name string) CmdFunc {
+		return func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+			if name == "[" {
+				if len(args) == 0 || args[len(args)-1] != "]" {
+					fmt.Fprintln(stderr, "[: missing `]'")
+					return ExitStatus(2)
+				}
+				args = args[:len(args)-1]
+			}
+			t := &testParser{r: r, args: args}
+			ok, err := t.eval()
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", name, err)
+				return ExitStatus(2)
+			}
+			if !ok {
+				return ExitStatus(1)
+			}
+			return nil
+		}
+	}
+	add("test", test("test"))
+	add("[", test("["))
+}
+
+// testParser evaluates the arguments of test. With up to four arguments
+// their meaning depends on how many there are, as POSIX specifies; longer
+// expressions are parsed with "!" binding tighter than -a, and -a tighter
+// than -o.
+type testParser struct {
+	r    *Registry
+	args []string
+	pos  int
+}
+
+func (t *testParser) eval() (bool, error) {
+	ok, err := t.fixed(t.args)
+	if err == errTestGeneral {
+		ok, err = t.or()
+		if err == nil && t.pos < len(t.args) {
+			err = fmt.Errorf("too many arguments")
+		}
+	}
+	return ok, err
+}
+
+// errTestGeneral tells eval that the arguments need the general parser.
+var errTestGeneral = fmt.Errorf("general expression")
+
+// fixed evaluates args by the POSIX rules for their number.
+func (t *testParser) fixed(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			ok, err := t.fixed(args[1:])
+			return !ok, err
+		}
+		if IsUnaryTest(args[0]) {
+			return t.r.UnaryTest(args[0], args[1]), nil
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if IsBinaryTest(args[1]) {
+			return t.r.BinaryTest(args[1], args[0], args[2])
+		}
+		if args[1] == "-a" || args[1] == "-o" {
+			break
+		}
+		if args[0] == "!" {
+			ok, err := t.fixed(args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return t.fixed(args[1:2])
+		}
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+	case 4:
+		if args[0] == "!" {
+			ok, err := t.fixed(args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return t.fixed(args[1:3])
+		}
+	}
+	return false, errTestGeneral
+}
+
+func (t *testParser) peek(n int) (string, bool) {
+	if t.pos+n < len(t.args) {
+		return t.args[t.pos+n], true
+	}
+	return "", false
+}
+
+func (t *testParser) or() (bool, error) {
+	ok, err := t.and()
+	for err == nil {
+		if arg, _ := t.peek(0); arg != "-o" {
+			break
+		}
+		t.pos++
+		var right bool
+		right, err = t.and()
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (t *testParser) and() (bool, error) {
+	ok, err := t.not()
+	for err == nil {
+		if arg, _ := t.peek(0); arg != "-a" {
+			break
+		}
+		t.pos++
+		var right bool
+		right, err = t.not()
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (t *testParser) not() (bool, error) {
+	if arg, _ := t.peek(0); arg == "!" {
+		t.pos++
+		ok, err := t.not()
+		return !ok, err
+	}
+	return t.primary()
+}
+
+func (t *testParser) primary() (bool, error) {
+	arg, ok := t.peek(0)
+	if !ok {
+		return false, fmt.Errorf("argument expected")
+	}
+	if op, ok := t.peek(1); ok && IsBinaryTest(op) {
+		right, ok := t.peek(2)
+		if !ok {
+			return false, fmt.Errorf("%s: argument expected", op)
+		}
+		t.pos += 3
+		return t.r.BinaryTest(op, arg, right)
+	}
+	if arg == "(" {
+		t.pos++
+		ok, err := t.or()
+		if err != nil {
+			return false, err
+		}
+		if closing, _ := t.peek(0); closing != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+		return ok, nil
+	}
+	if IsUnaryTest(arg) {
+		if operand, ok := t.peek(1); ok {
+			t.pos += 2
+			return t.r.UnaryTest(arg, operand), nil
+		}
+	}
+	t.pos++
+	return arg != "", nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import "os/user"
+
+// TildeDir returns the directory that a tilde prefix names, given the text
+// after the '~': "" is $HOME, "+" is the current directory, "-" is $OLDPWD,
+// "N", "+N" and "-N" are directory stack entries as dirs numbers them, and
+// anything else is a user name, looked up in the passwd database. It
+// reports false if there is no such directory, in which case the prefix
+// stays as it is.
+func (r *Registry) TildeDir(name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := r.Vars.Get("HOME"); ok {
+			return home, true
+		}
+		if u, err := user.Current(); err == nil {
+			return u.HomeDir, true
+		}
+		return "", false
+	case "+":
+		return r.Dir, true
+	case "-":
+		return r.Vars.Get("OLDPWD")
+	}
+	// ~N, ~+N and ~-N name entries of the directory stack
+	spec := name
+	if spec[0] != '+' && spec[0] != '-' {
+		spec = "+" + spec
+	}
+	if isStackIndex(spec) {
+		if i, ok := r.stackIndex(spec); ok {
+			return r.dirStack()[i], true
+		}
+		return "", false
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
@@ -0,0 +1,375 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// attrFlags are the variable attributes declare understands, in the order
+// bash prints them.
+const attrFlags = "aAirxlu"
+
+// declareOpts is a parsed set of declare options. set and clear hold the
+// attribute letters given with '-' and '+'.
+type declareOpts struct {
+	set, clear string
+	print      bool // -p
+	global     bool // -g
+	funcs      bool // -f
+	funcNames  bool // -F
+}
+
+func (r *Registry) registerVariableBuiltins(add func(string, CmdFunc)) {
+	declare := func(name string) CmdFunc {
+		return func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+			opts, names, err := parseDeclareOpts(name, args, attrFlags+"pgfF", stderr)
+			if err != nil {
+				return err
+			}
+			// Inside a function declare creates locals, unless -g is given
+			return r.declare(name, opts, names, r.FuncDepth > 0 && !opts.global, stdout, stderr)
+		}
+	}
+	add("declare", declare("declare"))
+	add("typeset", declare("typeset"))
+
+	add("local", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		if r.FuncDepth == 0 {
+			fmt.Fprintln(stderr, "local: can only be used in a function")
+			return ExitStatus(1)
+		}
+		opts, names, err := parseDeclareOpts("local", args, attrFlags+"p", stderr)
+		if err != nil {
+			return err
+		}
+		return r.declare("local", opts, names, true, stdout, stderr)
+	})
+
+	add("export", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		opts, names, err := parseDeclareOpts("export", args, "pn", stderr)
+		if err != nil {
+			return err
+		}
+		// "export -n" takes the export attribute away instead of adding it
+		if strings.Contains(opts.set, "n") {
+			opts.set, opts.clear = "", "x"
+		} else {
+			opts.set = "x"
+		}
+		if len(names) == 0 {
+			opts.print = true
+		}
+		return r.declare("export", opts, names, false, stdout, stderr)
+	})
+
+	add("readonly", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		opts, names, err := parseDeclareOpts("readonly", args, "p", stderr)
+		if err != nil {
+			return err
+		}
+		opts.set = "r"
+		if len(names) == 0 {
+			opts.print = true
+		}
+		return r.declare("readonly", opts, names, false, stdout, stderr)
+	})
+
+	add("unset", func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+		opts, names, err := parseDeclareOpts("unset", args, "vf", stderr)
+		if err != nil {
+			return err
+		}
+		onlyVars := strings.Contains(opts.set, "v")
+
+		var status error
+		for _, name := range names {
+			if opts.funcs {
+				r.UndefineFunction(name)
+				continue
+			}
+			base, _, _ := strings.Cut(name, "[")
+			if !vars.IsName(base) || (base != name && !strings.HasSuffix(name, "]")) {
+				fmt.Fprintf(stderr, "unset: `%s': not a valid identifier\n", name)
+				status = ExitStatus(1)
+				continue
+			}
+			// Without -v or -f, unset removes a function when there is no
+			// variable of that name
+			if _, ok := r.Vars.Var(base); !ok && !onlyVars {
+				r.UndefineFunction(name)
+				continue
+			}
+			if err := r.Vars.Unset(name); err != nil {
+				fmt.Fprintf(stderr, "unset: %v\n", err)
+				status = ExitStatus(1)
+			}
+		}
+		return status
+	})
+}
+
+// parseDeclareOpts splits the leading options of a declare-style builtin
+// from its operands. valid lists the option letters the builtin accepts.
+func parseDeclareOpts(name string, args []string, valid string, stderr io.Writer) (declareOpts, []string, error) {
+	var opts declareOpts
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			args = args[1:]
+			break
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		for _, c := range arg[1:] {
+			if !strings.ContainsRune(valid, c) {
+				fmt.Fprintf(stderr, "%s: %c%c: invalid option\n", name, arg[0], c)
+				return opts, nil, ExitStatus(2)
+			}
+			switch c {
+			case 'p':
+				opts.print = true
+			case 'g':
+				opts.global = true
+			case 'f':
+				opts.funcs = true
+			case 'F':
+				opts.funcNames = true
+			default:
+				if arg[0] == '-' {
+					opts.set += string(c)
+				} else {
+					opts.clear += string(c)
+				}
+			}
+		}
+		args = args[1:]
+	}
+	return opts, args, nil
+}
+
+// declare implements declare and the builtins built on it: it sets
+// attributes and values for each "name[=value]" operand, or prints
+// variables when there are none or -p is given.
+func (r *Registry) declare(builtin string, opts declareOpts, names []string, local bool, stdout, stderr io.Writer) error {
+	if opts.funcs || opts.funcNames {
+		return r.printFunctions(names, opts.funcNames, stdout)
+	}
+	if opts.print || len(names) == 0 {
+		return r.printVariables(builtin, opts.set, names, stdout, stderr)
+	}
+
+	var status error
+	for _, arg := range names {
+		name, value, hasValue := strings.Cut(arg, "=")
+		appending := hasValue && strings.HasSuffix(name, "+")
+		name = strings.TrimSuffix(name, "+")
+		if !vars.IsName(name) {
+			fmt.Fprintf(stderr, "%s: `%s': not a valid identifier\n", builtin, arg)
+			status = ExitStatus(1)
+			continue
+		}
+
+		readonly := false
+		var attrErr error
+		r.Vars.Declare(name, local, func(v *vars.Variable) {
+			readonly = v.ReadOnly
+			if readonly && strings.Contains(opts.clear, "r") {
+				return
+			}
+			for _, c := range opts.set {
+				if err := setAttr(v, c, true); err != nil && attrErr == nil {
+					attrErr = err
+				}
+			}
+			for _, c := range opts.clear {
+				if err := setAttr(v, c, false); err != nil && attrErr == nil {
+					attrErr = err
+				}
+			}
+		})
+		if readonly && (strings.Contains(opts.clear, "r") || hasValue) {
+			fmt.Fprintf(stderr, "%s: %s: readonly variable\n", builtin, name)
+			status = ExitStatus(1)
+			continue
+		}
+		if attrErr != nil {
+			fmt.Fprintf(stderr, "%s: %s: %v\n", builtin, name, attrErr)
+			status = ExitStatus(1)
+			continue
+		}
+
+		if hasValue {
+			var err error
+			if elems, ok := vars.ParseArray(value); ok {
+				err = r.Vars.SetArray(name, elems, appending)
+			} else if appending {
+				err = r.Vars.Append(name, value)
+			} else {
+				err = r.Vars.Set(name, value)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", builtin, err)
+				status = ExitStatus(1)
+				continue
+			}
+		}
+		// The readonly attribute goes on last, so "readonly x=1" can assign
+		if strings.Contains(opts.set, "r") {
+			r.Vars.Declare(name, local, func(v *vars.Variable) { v.ReadOnly = true })
+		}
+	}
+	return status
+}
+
+// setAttr turns the attribute for an option letter on or off. -l and -u
+// exclude each other, like in bash. A scalar can be turned into an array,
+// keeping its value as element 0, but an array stays an array.
+func setAttr(v *vars.Variable, c rune, on bool) error {
+	switch c {
+	case 'a', 'A':
+		if !on {
+			return fmt.Errorf("cannot destroy array variables in this way")
+		}
+		if c == 'a' && v.Assoc != nil {
+			return fmt.Errorf("cannot convert associative to indexed array")
+		}
+		if c == 'A' && v.Indexed != nil {
+			return fmt.Errorf("cannot convert indexed to associative array")
+		}
+		if v.IsArray() {
+			return nil
+		}
+		if c == 'a' {
+			v.Indexed = map[int64]string{}
+			if !v.Unset {
+				v.Indexed[0] = v.Value
+			}
+		} else {
+			v.Assoc = map[string]string{}
+			if !v.Unset {
+				v.Assoc["0"] = v.Value
+			}
+		}
+		v.Value = ""
+	case 'i':
+		v.Integer = on
+	case 'x':
+		v.Exported = on
+	case 'l':
+		v.Lower = on
+		if on {
+			v.Upper = false
+		}
+	case 'u':
+		v.Upper = on
+		if on {
+			v.Lower = false
+		}
+	}
+	return nil
+}
+
+// printVariables prints variables in the "declare -x NAME="value"" form
+// bash uses, which can be read back in as commands. With no names it
+// prints every variable that has all the attributes in filter.
+func (r *Registry) printVariables(builtin, filter string, names []string, stdout, stderr io.Writer) error {
+	var status error
+	if len(names) == 0 {
+		for _, name := range r.Vars.Names() {
+			v, _ := r.Vars.Var(name)
+			if strings.Trim(filter, attrString(v)) == "" {
+				fmt.Fprintln(stdout, declareLine(name, v))
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		v, ok := r.Vars.Var(name)
+		if !ok {
+			fmt.Fprintf(stderr, "%s: %s: not found\n", builtin, name)
+			status = ExitStatus(1)
+			continue
+		}
+		fmt.Fprintln(stdout, declareLine(name, v))
+	}
+	return status
+}
+
+// printFunctions prints shell functions for declare -f, as source that
+// defines them again, or only their names for declare -F.
+func (r *Registry) printFunctions(names []string, nameOnly bool, stdout io.Writer) error {
+	if len(names) == 0 {
+		for name := range r.Functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var status error
+	for _, name := range names {
+		fn, ok := r.Functions[name]
+		if !ok {
+			status = ExitStatus(1)
+			continue
+		}
+		if nameOnly {
+			fmt.Fprintf(stdout, "declare -f %s\n", name)
+		} else {
+			fmt.Fprintln(stdout, ast.Format(fn))
+		}
+	}
+	return status
+}
+
+func attrString(v vars.Variable) string {
+	var b strings.Builder
+	for _, c := range attrFlags {
+		var on bool
+		switch c {
+		case 'a':
+			on = v.Indexed != nil
+		case 'A':
+			on = v.Assoc != nil
+		case 'i':
+			on = v.Integer
+		case 'r':
+			on = v.ReadOnly
+		case 'x':
+			on = v.Exported
+		case 'l':
+			on = v.Lower
+		case 'u':
+			on = v.Upper
+		}
+		if on {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func declareLine(name string, v vars.Variable) string {
+	attrs := attrString(v)
+	if attrs == "" {
+		attrs = "-"
+	}
+	line := "declare -" + attrs + " " + name
+	switch {
+	case v.Unset:
+	case v.IsArray():
+		keys, values := v.Keys(), v.Values()
+		elems := make([]vars.Element, len(keys))
+		for i := range keys {
+			elems[i] = vars.Element{Key: keys[i], Keyed: true, Value: values[i]}
+		}
+		line += "=" + vars.FormatArray(elems)
+	default:
+		line += "=" + vars.Quote(v.Value)
+	}
+	return line
+}
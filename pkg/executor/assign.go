@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// assignment is an assignment word split into its parts: "name=value",
+// "name+=value", "name[sub]=value", or "name=(elements)" for an array.
+type assignment struct {
+	name      string // includes the expanded subscript, as in "arr[2]"
+	appending bool
+	value     string
+	elems     []vars.Element
+	array     bool
+}
+
+// expandAssignment expands the parts of an assignment.
+func (e *expander) expandAssignment(assign *ast.Assignment) (assignment, error) {
+	a := assignment{name: assign.Name, appending: assign.Append}
+	if assign.Index != nil {
+		sub, err := e.expandString(assign.Index)
+		if err != nil {
+			return a, err
+		}
+		a.name += "[" + sub + "]"
+	}
+
+	if assign.IsArray {
+		elems, err := e.expandElements(assign.Array)
+		a.elems, a.array = elems, true
+		return a, err
+	}
+	var err error
+	a.value, err = e.expandString(assign.Value)
+	return a, err
+}
+
+// expandElements expands the elements of "name=(...)". An element like
+// "[key]=value" sets the element under key; any other element may expand
+// to several, as "$@" does.
+func (e *expander) expandElements(array []ast.ArrayElem) ([]vars.Element, error) {
+	elems := []vars.Element{}
+	for _, elem := range array {
+		if elem.Key != nil {
+			key, err := e.expandString(elem.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := e.expandString(elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, vars.Element{Key: key, Keyed: true, Value: value})
+			continue
+		}
+		values, err := e.expandWords([]*ast.Word{elem.Value})
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			elems = append(elems, vars.Element{Value: value})
+		}
+	}
+	return elems, nil
+}
+
+// String formats the expanded assignment as a word again, with an array
+// value in the form vars.ParseArray reads.
+func (a assignment) String() string {
+	op := "="
+	if a.appending {
+		op = "+="
+	}
+	if a.array {
+		return a.name + op + vars.FormatArray(a.elems)
+	}
+	return a.name + op + a.value
+}
+
+// apply performs the assignment on the shell's variables.
+func (a assignment) apply(reg *commands.Registry) error {
+	switch {
+	case a.array:
+		return reg.Vars.SetArray(a.name, a.elems, a.appending)
+	case a.appending:
+		return reg.Vars.Append(a.name, a.value)
+	}
+	return reg.Vars.Set(a.name, a.value)
+}
+
+// declarationArg expands an argument of a declaration builtin such as
+// "local arr=(a b)". An assignment is passed on as a single argument, with
+// an array value in the form vars.ParseArray reads; other arguments expand
+// as usual.
+func (e *expander) declarationArg(word *ast.Word) ([]string, error) {
+	if word.Assign == nil {
+		return e.expandWords([]*ast.Word{word})
+	}
+	a, err := e.expandAssignment(word.Assign)
+	if err != nil {
+		return nil, err
+	}
+	return []string{a.String()}, nil
+}
+
+// isDeclaration reports whether a command is a builtin that takes
+// assignments as arguments.
+func isDeclaration(word *ast.Word) bool {
+	switch name, _ := word.Lit(); name {
+	case "declare", "typeset", "local", "export", "readonly":
+		return true
+	}
+	return false
+}
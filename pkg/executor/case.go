@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"io"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/pattern"
+)
+
+// executeCase runs the body of the first item whose pattern matches the
+// word. Its status is that of the last body run, or 0 if nothing matched.
+func executeCase(n *ast.CaseNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	e := newExpander(reg, stdin, stdout, stderr)
+	defer e.finish()
+	word, err := e.expandString(n.Word)
+	if err != nil {
+		return expansionError(err, reg, stderr)
+	}
+
+	var status error
+	for i := 0; i < len(n.Items); i++ {
+		matched, err := e.caseMatches(n.Items[i].Patterns, word)
+		if err != nil {
+			return expansionError(err, reg, stderr)
+		}
+		if !matched {
+			continue
+		}
+
+		// ";&" runs the next body without testing its patterns
+		for {
+			status = Execute(n.Items[i].Body, reg, stdin, stdout, stderr)
+			if isControl(status) || n.Items[i].Terminator != ";&" || i+1 == len(n.Items) {
+				break
+			}
+			i++
+		}
+
+		if isControl(status) || n.Items[i].Terminator != ";;&" {
+			return status
+		}
+	}
+	return status
+}
+
+func (e *expander) caseMatches(patterns []*ast.Word, word string) (bool, error) {
+	for _, p := range patterns {
+		expanded, err := e.expandPattern(p)
+		if err != nil {
+			return false, err
+		}
+		if pattern.Match(expanded, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
+	"github.com/codecrafters-io/shell-starter-go/pkg/parser"
+)
+
+func TestCommandPaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// An executable file without a #! line runs as a shell script (ENOEXEC)
+	script := write("script", "echo script $1\n", 0755)
+	noExec := write("noexec", "echo hi\n", 0644)
+
+	tests := []struct {
+		command    string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{script + " arg", "script arg\n", "", 0},
+		{noExec, "", noExec + ": Permission denied\n", 126},
+		{dir, "", dir + ": Is a directory\n", 126},
+		{filepath.Join(dir, "missing"), "", filepath.Join(dir, "missing") + ": No such file or directory\n", 127},
+		{"no-such-command-anywhere", "", "no-such-command-anywhere: command not found\n", 127},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.command))
+		node := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Fatalf("%q: %s", tt.command, strings.Join(errs, "; "))
+		}
+		var stdout, stderr bytes.Buffer
+		err := Execute(node, commands.NewRegistry(), nil, &stdout, &stderr)
+		if status := exitStatus(err); status != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.command, status, tt.wantStatus)
+		}
+		if stdout.String() != tt.wantOut {
+			t.Errorf("%s: stdout %q, want %q", tt.command, stdout.String(), tt.wantOut)
+		}
+		if stderr.String() != tt.wantErr {
+			t.Errorf("%s: stderr %q, want %q", tt.command, stderr.String(), tt.wantErr)
+		}
+	}
+}
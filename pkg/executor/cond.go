@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/arith"
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/pattern"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// executeCond runs "[[ expr ]]". Its status is 0 when expr is true and 1
+// when it is false; an invalid regular expression gives 2.
+func executeCond(n *ast.CondNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	e := newExpander(reg, stdin, stdout, stderr)
+	defer e.finish()
+	ok, err := e.evalCond(n.Expr)
+	if _, isStatus := err.(commands.ExitStatus); err != nil && !isStatus {
+		return expansionError(err, reg, stderr)
+	}
+	if err == nil && !ok {
+		err = commands.ExitStatus(1)
+	}
+	return err
+}
+
+// evalCond evaluates a [[ ]] expression. && and || only expand their right
+// side when it decides the result.
+func (e *expander) evalCond(x ast.CondExpr) (bool, error) {
+	switch x := x.(type) {
+	case *ast.CondBinary:
+		left, err := e.evalCond(x.Left)
+		if err != nil || left == (x.Op == "||") {
+			return left, err
+		}
+		return e.evalCond(x.Right)
+
+	case *ast.CondNot:
+		ok, err := e.evalCond(x.X)
+		return !ok, err
+
+	case *ast.CondWord:
+		s, err := e.expandString(x.Word)
+		return s != "", err
+
+	case *ast.CondUnary:
+		s, err := e.expandString(x.Arg)
+		if err != nil {
+			return false, err
+		}
+		return e.reg.UnaryTest(x.Op, s), nil
+
+	case *ast.CondCompare:
+		return e.evalCompare(x)
+	}
+	return false, nil
+}
+
+func (e *expander) evalCompare(x *ast.CondCompare) (bool, error) {
+	left, err := e.expandString(x.Left)
+	if err != nil {
+		return false, err
+	}
+
+	switch x.Op {
+	case "==", "=", "!=":
+		pat, err := e.expandPattern(x.Right)
+		if err != nil {
+			return false, err
+		}
+		return pattern.Match(pat, left) == (x.Op != "!="), nil
+
+	case "=~":
+		expr, err := e.expandRegex(x.Right)
+		if err != nil {
+			return false, err
+		}
+		re, err := regexp.CompilePOSIX(expr)
+		if err != nil {
+			return false, commands.ExitStatus(2)
+		}
+		// BASH_REMATCH gets the match and what each group matched
+		m := re.FindStringSubmatch(left)
+		elems := make([]vars.Element, len(m))
+		for i, s := range m {
+			elems[i] = vars.Element{Value: s}
+		}
+		e.reg.Vars.SetArray("BASH_REMATCH", elems, false)
+		return m != nil, nil
+
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		// The operands are arithmetic expressions
+		right, err := e.expandString(x.Right)
+		if err != nil {
+			return false, err
+		}
+		a, err := e.condArith(left)
+		if err != nil {
+			return false, err
+		}
+		b, err := e.condArith(right)
+		if err != nil {
+			return false, err
+		}
+		return e.reg.BinaryTest(x.Op, fmt.Sprint(a), fmt.Sprint(b))
+	}
+
+	right, err := e.expandString(x.Right)
+	if err != nil {
+		return false, err
+	}
+	return e.reg.BinaryTest(x.Op, left, right)
+}
+
+// condArith evaluates an operand of an arithmetic comparison in [[ ]].
+func (e *expander) condArith(expr string) (int64, error) {
+	v, err := arith.Eval(expr, e.reg.Vars)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %v\n", expr, err)
+		return 0, commands.ExitStatus(1)
+	}
+	return v, nil
+}
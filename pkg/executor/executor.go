@@ -1,58 +1,56 @@
 package executor
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"strings"
-	"sync"
 	"syscall"
 	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
 	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
 )
 
 func Execute(node ast.Node, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
 	switch n := node.(type) {
 	case *ast.BlockNode :
+		// A block's status is the status of the last statement it ran
+		var err error
 		for _,stmt := range n.Statements {
-			if err := Execute(stmt,reg,stdin,stdout,stderr); err != nil {
-				// break
+			// set -n reads commands without running them, except at a prompt
+			if reg.Options.Noexec && !reg.Interactive {
+				break
+			}
+			err = Execute(stmt,reg,stdin,stdout,stderr)
+			reg.LastStatus = exitStatus(err)
+			if isControl(err) || reg.ExitSignal {
+				break
 			}
 		}
-		return nil
+		return err
 	case *ast.PipeNode:
-		// Create pipe
-		r, w, err := os.Pipe()
-		if err != nil {
-			return err
+		err := executePipe(n, reg, stdin, stdout, stderr)
+		if !n.Negate {
+			checkErrexit(err, reg)
 		}
-
-		var wg sync.WaitGroup
-		wg.Add(1)
-
-		// Run Left side (write to pipe)
-		go func() {
-			defer wg.Done()
-			defer w.Close()
-			Execute(n.Left, reg, stdin, w, stderr)
-		}()
-
-		// Run Right side (read from pipe)
-		err = Execute(n.Right, reg, r, stdout, stderr)
-		wg.Wait()
 		return err
 
 	case *ast.RedirectNode:
 		return executeRedirect(n, reg, stdin, stdout, stderr)
 
 	case *ast.CommandNode:
-		if len(n.Args) == 0 {
-			return nil
-		}
-		return executeCommand(n.Args, reg, stdin, stdout, stderr)
+		err := executeSimpleCommand(n, reg, stdin, stdout, stderr)
+		setPipeStatus(reg, exitStatus(err))
+		checkErrexit(err, reg)
+		return err
 	case *ast.IfNode:
-		err := Execute(n.Condition, reg, stdin, stdout, stderr)
+		err := executeCondition(n.Condition, reg, stdin, stdout, stderr)
+		if isControl(err) {
+			return err
+		}
 		
 		if err == nil {
 			return Execute(n.Then, reg, stdin, stdout, stderr)
@@ -62,21 +60,52 @@ func Execute(node ast.Node, reg *commands.Registry, stdin io.Reader, stdout, std
 			}
 		}
 		return nil
+	case *ast.WhileNode:
+		return executeWhile(n, reg, stdin, stdout, stderr)
+	case *ast.ForNode:
+		return executeFor(n, reg, stdin, stdout, stderr)
+	case *ast.ArithForNode:
+		return executeArithFor(n, reg, stdin, stdout, stderr)
+	case *ast.CaseNode:
+		return executeCase(n, reg, stdin, stdout, stderr)
+	case *ast.FunctionNode:
+		reg.DefineFunction(n)
+		return nil
+	case *ast.SubshellNode:
+		err := executeSubshell(n, reg, stdin, stdout, stderr)
+		checkErrexit(err, reg)
+		return err
+	case *ast.ArithNode:
+		v, err := evalArith(n.Expr, reg, stdin, stderr)
+		if err == nil && v == 0 {
+			err = commands.ExitStatus(1)
+		}
+		checkErrexit(err, reg)
+		return err
+	case *ast.CondNode:
+		err := executeCond(n, reg, stdin, stdout, stderr)
+		checkErrexit(err, reg)
+		return err
 	case *ast.BinaryNode:
 		switch n.Operator {
 		case "&":
 			// Start the background work synchronously so [N] pid prints before the next prompt.
-			if cmdNode, ok := n.Left.(*ast.CommandNode); ok {
+			if cmdNode, ok := n.Left.(*ast.CommandNode); ok && len(cmdNode.Assigns) == 0 && !mayCallFunction(cmdNode, reg) && !hasProcSubst(cmdNode) {
 				// Simple command: start process now, wait in goroutine
-				executeBackgroundCommand(cmdNode.Args, reg, stdin, stdout, stderr)
+				args, err := newExpander(reg, stdin, stdout, stderr).expandWords(cmdNode.Args)
+				if err != nil {
+					return expansionError(err, reg, stderr)
+				}
+				executeBackgroundCommand(args, reg, stdin, stdout, stderr)
 			} else {
 				// Complex expression (e.g. "sleep 1 && echo done &"):
 				// register job and print notification now, run+cleanup in goroutine
 				bgNode := n.Left
 				jobID := reg.AddJob(0, bgNode.String(), nil)
 				fmt.Fprintf(stdout, "[%d] %d\n", jobID, 0)
+				bg := reg.NewSubshell()
 				go func() {
-					Execute(bgNode, reg, stdin, stdout, stderr)
+					Execute(bgNode, bg, stdin, stdout, stderr)
 					reg.RemoveJob(jobID)
 				}()
 			}
@@ -88,14 +117,20 @@ func Execute(node ast.Node, reg *commands.Registry, stdin io.Reader, stdout, std
 			return nil
 
 		case "&&":
-			err := Execute(n.Left, reg, stdin, stdout, stderr)
+			err := executeCondition(n.Left, reg, stdin, stdout, stderr)
+			if isControl(err) {
+				return err
+			}
 			if err == nil && n.Right != nil {
 				return Execute(n.Right, reg, stdin, stdout, stderr)
 			}
 			return err
 
 		case "||":
-			err := Execute(n.Left, reg, stdin, stdout, stderr)
+			err := executeCondition(n.Left, reg, stdin, stdout, stderr)
+			if isControl(err) {
+				return err
+			}
 			if err != nil && n.Right != nil {
 				return Execute(n.Right, reg, stdin, stdout, stderr)
 			}
@@ -106,8 +141,14 @@ func Execute(node ast.Node, reg *commands.Registry, stdin io.Reader, stdout, std
 }
 
 func executeRedirect(node *ast.RedirectNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+    e := newExpander(reg, stdin, stdout, stderr)
+    defer e.finish()
+    location, err := e.expandString(node.Location)
+    if err != nil {
+        return expansionError(err, reg, stderr)
+    }
     if node.Type == "<" { //If a user runs cat < input.txt, previous code will try to open input.txt for writing and truncate it!
-        f, err := os.Open(node.Location)
+        f, err := os.Open(reg.Path(location))
         if err != nil {
             fmt.Fprintf(stderr, "error opening file: %v\n", err)
             return err
@@ -124,7 +165,15 @@ func executeRedirect(node *ast.RedirectNode, reg *commands.Registry, stdin io.Re
         flags |= os.O_TRUNC
     }
 
-	f, err := os.OpenFile(node.Location, flags, 0644)
+	// With set -C, ">" refuses to overwrite a regular file; ">|" still can
+	if reg.Options.Noclobber && !strings.HasSuffix(node.Type, ">>") && !strings.HasSuffix(node.Type, "|") {
+		if info, err := os.Stat(reg.Path(location)); err == nil && info.Mode().IsRegular() {
+			fmt.Fprintf(stderr, "%s: cannot overwrite existing file\n", location)
+			return commands.ExitStatus(1)
+		}
+	}
+
+	f, err := os.OpenFile(reg.Path(location), flags, 0644)
 	if err != nil {
 		fmt.Fprintf(stderr, "error opening file: %v\n", err)
 		return err
@@ -141,25 +190,236 @@ func executeRedirect(node *ast.RedirectNode, reg *commands.Registry, stdin io.Re
 	return Execute(node.Stmt, reg, stdin, stdout, stderr)
 }
 
+// executeSimpleCommand runs a command with its leading variable assignments.
+// On their own the assignments set shell variables; before a command they
+// are exported to it and only last while it runs.
+func executeSimpleCommand(n *ast.CommandNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	e := newExpander(reg, stdin, stdout, stderr)
+	defer e.finish()
+	args, err := e.expandArgs(n.Args)
+	if err != nil {
+		return expansionError(err, reg, stderr)
+	}
+
+	temporary := len(args) > 0 && len(n.Assigns) > 0
+	if temporary {
+		reg.Vars.PushScope()
+		defer reg.Vars.PopScope()
+	}
+	for _, assign := range n.Assigns {
+		a, err := e.expandAssignment(assign)
+		if err != nil {
+			return expansionError(err, reg, stderr)
+		}
+		if reg.Options.Xtrace {
+			e.traceAssignment(a)
+		}
+		if temporary {
+			name, _, _ := strings.Cut(a.name, "[")
+			outer, _ := reg.Vars.Var(name)
+			if outer.ReadOnly {
+				fmt.Fprintf(stderr, "%s: readonly variable\n", name)
+				return commands.ExitStatus(1)
+			}
+			// The temporary variable keeps the attributes of the one it hides
+			reg.Vars.Declare(name, true, func(v *vars.Variable) {
+				*v = outer
+				v.Exported = true
+			})
+		}
+		if err := a.apply(reg); err != nil {
+			fmt.Fprintln(stderr, err)
+			return commands.ExitStatus(1)
+		}
+	}
+
+	// Without a command, the status is that of the last command substitution
+	if len(args) == 0 {
+		if e.substStatus > 0 {
+			return commands.ExitStatus(e.substStatus)
+		}
+		return nil
+	}
+	if reg.Options.Xtrace {
+		e.trace(args...)
+	}
+	return executeCommand(args, reg, stdin, stdout, stderr)
+}
+
+// expandArgs expands the words of a command into its arguments. The
+// arguments of declaration builtins may be array assignments.
+func (e *expander) expandArgs(words []*ast.Word) ([]string, error) {
+	if len(words) == 0 || !isDeclaration(words[0]) {
+		return e.expandWords(words)
+	}
+	var args []string
+	for _, word := range words {
+		fields, err := e.declarationArg(word)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fields...)
+	}
+	return args, nil
+}
+
 func executeCommand(args []string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
 	cmdName := args[0]
 	cmdArgs := args[1:]
 
+	if fn, ok := reg.Functions[cmdName]; ok {
+		return callFunction(fn, cmdArgs, reg, stdin, stdout, stderr)
+	}
+
 	if fn, ok := reg.Builtins[cmdName]; ok {
-		fn(cmdArgs, stdin, stdout, stderr)
-		return nil
+		return fn(cmdArgs, stdin, stdout, stderr)
 	}
 
-	if _, err := exec.LookPath(cmdName); err == nil {
-		cmd := exec.Command(cmdName, cmdArgs...)
+	if path, err := reg.LookPath(cmdName); err == nil {
+		cmd := exec.Command(path, cmdArgs...)
+		cmd.Args[0] = cmdName
+		cmd.Dir = reg.Dir
+		cmd.Env = reg.Vars.Environ()
 		cmd.Stdin = stdin
 		cmd.Stdout = stdout
 		cmd.Stderr = stderr
-		return cmd.Run()
+		cmd.ExtraFiles = procSubstFiles(reg)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		var pathErr *fs.PathError
+		switch {
+		case errors.Is(err, syscall.ENOEXEC):
+			// An executable file without a "#!" line is a shell script
+			return runScriptFile(path, args, reg, stdin, stdout, stderr)
+		case err != nil && !errors.As(err, &exitErr):
+			// The command could not be started
+			if errors.As(err, &pathErr) {
+				err = pathErr.Err
+			}
+			fmt.Fprintf(stderr, "%s: %v\n", cmdName, err)
+			return commands.ExitStatus(126)
+		}
+		return err
 	}
 
-	fmt.Fprintf(stderr, "%s: command not found\n", cmdName)
-	return fmt.Errorf("not found")
+	return commandNotFound(cmdName, reg, stderr)
+}
+
+// commandNotFound reports a command that cannot be run and returns its
+// status. A name with a slash names a file directly, so if the file exists
+// but is not executable the status is 126 rather than 127.
+func commandNotFound(name string, reg *commands.Registry, stderr io.Writer) error {
+	if !strings.Contains(name, "/") {
+		fmt.Fprintf(stderr, "%s: command not found\n", name)
+		return commands.ExitStatus(127)
+	}
+	info, err := os.Stat(reg.Path(name))
+	switch {
+	case err != nil:
+		fmt.Fprintf(stderr, "%s: No such file or directory\n", name)
+		return commands.ExitStatus(127)
+	case info.IsDir():
+		fmt.Fprintf(stderr, "%s: Is a directory\n", name)
+	default:
+		fmt.Fprintf(stderr, "%s: Permission denied\n", name)
+	}
+	return commands.ExitStatus(126)
+}
+
+// executeSubshell runs a subshell in a copy of the shell state. exit, break
+// and return inside it only end the subshell.
+func executeSubshell(n *ast.SubshellNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	sub := reg.NewSubshell()
+	err := Execute(n.Body, sub, stdin, stdout, stderr)
+	if sub.ExitSignal {
+		err = commands.ExitStatus(sub.ExitCode)
+	} else if isControl(err) {
+		err = commands.ExitStatus(exitStatus(err))
+	}
+	if exitStatus(err) == 0 {
+		return nil
+	}
+	return err
+}
+
+// mayCallFunction reports whether a simple command may call a shell
+// function. A command name that needs expansion is not expanded here, since
+// that could run command substitutions twice, so it counts as a possible
+// call.
+func mayCallFunction(n *ast.CommandNode, reg *commands.Registry) bool {
+	if len(n.Args) == 0 {
+		return false
+	}
+	name, ok := n.Args[0].Lit()
+	if !ok {
+		return true
+	}
+	_, ok = reg.Functions[name]
+	return ok
+}
+
+// executeCondition runs a command whose status is being tested, such as an
+// if condition or the left side of "&&". set -e does not apply inside it.
+func executeCondition(node ast.Node, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	reg.CondDepth++
+	defer func() { reg.CondDepth-- }()
+	return Execute(node, reg, stdin, stdout, stderr)
+}
+
+// checkErrexit makes the shell exit after a failed command when set -e is
+// on, unless the command's status is being tested.
+func checkErrexit(err error, reg *commands.Registry) {
+	if !reg.Options.Errexit || reg.CondDepth > 0 || reg.ExitSignal || isControl(err) {
+		return
+	}
+	if status := exitStatus(err); status != 0 {
+		reg.ExitSignal = true
+		reg.ExitCode = status
+	}
+}
+
+// hasProcSubst reports whether a simple command has a process substitution
+// among its words.
+func hasProcSubst(n *ast.CommandNode) bool {
+	for _, word := range n.Args {
+		for _, part := range word.Parts {
+			if _, ok := part.(*ast.ProcSubst); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exitStatus converts the error returned by Execute into a shell exit status.
+func exitStatus(err error) int {
+	var status commands.ExitStatus
+	var exitErr *exec.ExitError
+	if ret, ok := err.(*commands.FunctionReturn); ok {
+		return ret.Status
+	}
+	switch {
+	case err == nil, isControl(err):
+		return 0
+	case errors.As(err, &status):
+		return int(status)
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// isControl reports whether err is a break, continue or return unwinding
+// the executor rather than a failed command.
+func isControl(err error) bool {
+	switch err.(type) {
+	case *commands.LoopControl, *commands.FunctionReturn:
+		return true
+	}
+	return false
 }
 
 func executeBackgroundCommand(args []string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
@@ -170,8 +430,11 @@ func executeBackgroundCommand(args []string, reg *commands.Registry, stdin io.Re
 	cmdArgs := args[1:]
 	cmdString := strings.Join(args, " ")
 
-	if _, err := exec.LookPath(cmdName); err == nil {
-		cmd := exec.Command(cmdName, cmdArgs...)
+	if path, err := reg.LookPath(cmdName); err == nil {
+		cmd := exec.Command(path, cmdArgs...)
+		cmd.Args[0] = cmdName
+		cmd.Dir = reg.Dir
+		cmd.Env = reg.Vars.Environ()
 		cmd.Stdin = stdin
 		cmd.Stdout = stdout
 		cmd.Stderr = stderr
@@ -187,6 +450,5 @@ func executeBackgroundCommand(args []string, reg *commands.Registry, stdin io.Re
 		return nil
 	}
 
-	fmt.Fprintf(stderr, "%s: command not found\n", cmdName)
-	return fmt.Errorf("not found")
+	return commandNotFound(cmdName, reg, stderr)
 }
\ No newline at end of file
@@ -0,0 +1,515 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
+	"github.com/codecrafters-io/shell-starter-go/pkg/parser"
+	"github.com/codecrafters-io/shell-starter-go/pkg/pattern"
+)
+
+// expander expands words. After brace expansion, tilde, parameter, command
+// and arithmetic expansion happen in one pass over the parts of a word; then
+// the unquoted results are split into fields on IFS, fields with unquoted
+// glob characters are replaced by the paths they match, and quotes are
+// removed.
+type expander struct {
+	reg    *commands.Registry
+	stdin  io.Reader // for command and process substitutions
+	stdout io.Writer // for >(...)
+	stderr io.Writer
+
+	// split enables field splitting and pathname expansion. They only apply
+	// to words that may become several arguments, not to strings such as a
+	// redirection target or a case word.
+	split bool
+
+	// regex makes pat a regular expression instead of a pattern: quoted
+	// text in it matches literally, as on the right of "=~".
+	regex bool
+
+	// State of the word being expanded. cur holds the text of the current
+	// field and pat the same text as a pattern, with quoted glob characters
+	// escaped by a backslash.
+	fields  []field
+	cur     strings.Builder
+	pat     strings.Builder
+	quoted  bool // the field has quoted text, so it is kept even when empty
+	glob    bool // the field has unquoted glob characters
+	pending bool // IFS whitespace ended the field; it ends before more text
+
+	// emptyList is set when the last parameter expanded was a list such as
+	// "$@" or "${arr[@]}" with no elements.
+	emptyList bool
+
+	// substStatus is the status of the last command substitution, or -1 if
+	// there was none. A command with no words other than assignments
+	// returns it.
+	substStatus int
+
+	// procs are the process substitutions started so far. finish cleans
+	// them up once the command that uses them is done.
+	procs []procSubst
+
+	// err is the first expansion that failed, such as a bad arithmetic
+	// expression. The command is then not run.
+	err error
+}
+
+// field is one field of an expanded word.
+type field struct {
+	text    string
+	pattern string
+	glob    bool
+}
+
+// procSubst is a running process substitution.
+type procSubst struct {
+	file *os.File // the end of the pipe that the command using it gets
+	done chan struct{}
+}
+
+func newExpander(reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) *expander {
+	return &expander{reg: reg, stdin: stdin, stdout: stdout, stderr: stderr, substStatus: -1}
+}
+
+// nested returns an expander for a word inside an expansion, such as the
+// word in ${x:-word}, so the state of the outer word is left alone.
+func (e *expander) nested() *expander {
+	return newExpander(e.reg, e.stdin, e.stdout, e.stderr)
+}
+
+// expandWords expands the words of a command into its final arguments. An
+// unquoted word that expands to nothing is dropped, so "echo $UNSET x" has
+// a single argument.
+func (e *expander) expandWords(words []*ast.Word) ([]string, error) {
+	e.split = true
+	var args []string
+	for _, w := range expandBraces(words) {
+		for _, f := range e.expandWord(w) {
+			if f.glob && !e.reg.Options.Noglob {
+				if matches := pattern.Glob(f.pattern, e.reg.Dir); len(matches) > 0 {
+					args = append(args, matches...)
+					continue
+				}
+			}
+			args = append(args, f.text)
+		}
+		if e.err != nil {
+			return nil, e.err
+		}
+	}
+	return args, nil
+}
+
+// expandBraces performs brace expansion, which comes before all other
+// expansions and only applies to words that may become several arguments.
+func expandBraces(words []*ast.Word) []*ast.Word {
+	var expanded []*ast.Word
+	for _, w := range words {
+		raws := parser.ExpandBraces(w.Raw)
+		if len(raws) == 1 {
+			expanded = append(expanded, w)
+			continue
+		}
+		for _, raw := range raws {
+			expanded = append(expanded, parser.ParseWord(raw))
+		}
+	}
+	return expanded
+}
+
+// expandString expands a word where the result must be a single string,
+// such as a redirection target or an assignment value.
+func (e *expander) expandString(w *ast.Word) (string, error) {
+	e.split = false
+	var texts []string
+	for _, f := range e.expandWord(w) {
+		texts = append(texts, f.text)
+	}
+	return strings.Join(texts, " "), e.err
+}
+
+// expandPattern expands a word that is used as a pattern. Quoted parts of
+// the word match literally.
+func (e *expander) expandPattern(w *ast.Word) (string, error) {
+	e.split = false
+	var pats []string
+	for _, f := range e.expandWord(w) {
+		pats = append(pats, f.pattern)
+	}
+	return strings.Join(pats, " "), e.err
+}
+
+// expandRegex expands the word on the right of "=~" into a regular
+// expression. Quoted parts of the word match literally.
+func (e *expander) expandRegex(w *ast.Word) (string, error) {
+	e.regex = true
+	defer func() { e.regex = false }()
+	return e.expandPattern(w)
+}
+
+// textMode says what the text given to expandText is.
+type textMode int
+
+const (
+	textString  textMode = iota // expands to a single string
+	textPattern                 // a pattern, where quoted parts match literally
+	textArith                   // an arithmetic expression, where ~ is an operator
+)
+
+// expandText expands raw text that was not parsed as a word, such as an
+// arithmetic expression or the word of a ${x:-word} operator.
+func (e *expander) expandText(text string, mode textMode) (string, error) {
+	switch mode {
+	case textPattern:
+		return e.expandPattern(parser.ParseWord(text))
+	case textArith:
+		return e.expandString(parser.ParseArith(text))
+	}
+	return e.expandString(parser.ParseWord(text))
+}
+
+// fatalError is an expansion error that makes a non-interactive shell
+// exit, such as expanding an unset parameter with set -u.
+type fatalError struct {
+	error
+}
+
+// expansionError reports a failed expansion and returns the status of the
+// command that could not run.
+func expansionError(err error, reg *commands.Registry, stderr io.Writer) error {
+	fmt.Fprintln(stderr, err)
+	if _, ok := err.(fatalError); ok && !reg.Interactive {
+		reg.ExitSignal = true
+		reg.ExitCode = 1
+	}
+	return commands.ExitStatus(1)
+}
+
+// expandWord expands the parts of a word into fields.
+func (e *expander) expandWord(w *ast.Word) []field {
+	e.fields = nil
+	e.resetField()
+	for _, part := range w.Parts {
+		e.expandPart(part, false)
+	}
+	e.endField()
+	return e.fields
+}
+
+func (e *expander) expandPart(part ast.WordPart, quoted bool) {
+	switch p := part.(type) {
+	case *ast.Literal:
+		if quoted {
+			e.writeQuoted(p.Text)
+		} else {
+			e.writeLiteral(p.Text)
+		}
+	case *ast.SingleQuoted:
+		e.writeQuoted(p.Text)
+		e.quoted = true
+	case *ast.DoubleQuoted:
+		e.emptyList = false
+		for _, inner := range p.Parts {
+			e.expandPart(inner, true)
+		}
+		// "" is an empty field, but "$@" with no parameters is no field
+		onlyList := false
+		if len(p.Parts) == 1 {
+			_, onlyList = p.Parts[0].(*ast.ParamExp)
+		}
+		if !onlyList || !e.emptyList {
+			e.flushPending()
+			e.quoted = true
+		}
+	case *ast.ParamExp:
+		e.emptyList = false
+		switch {
+		case p.Braced:
+			e.expandBraced(p.Body, quoted)
+		case p.Body == "@" || p.Body == "*":
+			e.expandList(e.reg.Positional, p.Body == "*", quoted)
+		default:
+			value, ok := lookupParamSet(p.Body, e.reg)
+			if !ok && e.reg.Options.Nounset {
+				if e.err == nil {
+					e.err = fatalError{fmt.Errorf("%s: unbound variable", p.Body)}
+				}
+				return
+			}
+			e.writeExpansion(value, quoted)
+		}
+	case *ast.CmdSubst:
+		e.writeExpansion(e.commandSubst(p.Source), quoted)
+	case *ast.ProcSubst:
+		e.writeQuoted(e.processSubst(p))
+	case *ast.ArithExp:
+		if v, ok := e.opArith(p.Expr); ok {
+			e.writeExpansion(strconv.FormatInt(v, 10), quoted)
+		}
+	case *ast.Tilde:
+		e.expandTilde(p)
+	}
+}
+
+// expandTilde expands a tilde prefix to the directory it names. The result
+// is not split or globbed. A prefix that names no directory, such as an
+// unknown user, is kept as it is.
+func (e *expander) expandTilde(t *ast.Tilde) {
+	if dir, ok := e.reg.TildeDir(t.User); ok {
+		e.writeQuoted(dir)
+		return
+	}
+	e.writeLiteral("~" + t.User)
+}
+
+// commandSubst runs the commands of a command substitution in a subshell
+// and returns their output without trailing newlines.
+func (e *expander) commandSubst(src string) string {
+	p := parser.New(lexer.New(src))
+	p.SetAliases(e.reg.Aliases)
+	program := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		if e.err == nil {
+			e.err = fmt.Errorf("command substitution: %s", errs[0])
+		}
+		return ""
+	}
+
+	sub := e.reg.NewSubshell()
+	var out bytes.Buffer
+	err := Execute(program, sub, e.stdin, &out, e.stderr)
+	status := exitStatus(err)
+	if sub.ExitSignal {
+		status = sub.ExitCode
+	}
+	e.reg.LastStatus = status
+	e.substStatus = status
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// processSubst starts the command of a process substitution on one end of
+// a pipe and returns a /dev/fd path for the other end. With <(...) the
+// command writes to the pipe and with >(...) it reads from it.
+func (e *expander) processSubst(p *ast.ProcSubst) string {
+	parsed := parser.New(lexer.New(p.Source))
+	parsed.SetAliases(e.reg.Aliases)
+	program := parsed.Parse()
+	if errs := parsed.Errors(); len(errs) > 0 {
+		if e.err == nil {
+			e.err = fmt.Errorf("process substitution: %s", errs[0])
+		}
+		return ""
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return ""
+	}
+
+	inner, outer := w, r
+	stdin, stdout := e.stdin, io.Writer(w)
+	if p.Output {
+		inner, outer = r, w
+		stdin, stdout = r, e.stdout
+	}
+	sub := e.reg.NewSubshell()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Execute(program, sub, stdin, stdout, e.stderr)
+		inner.Close()
+	}()
+
+	fd := int(outer.Fd())
+	e.reg.ProcFiles[fd] = outer
+	e.procs = append(e.procs, procSubst{file: outer, done: done})
+	return fmt.Sprintf("/dev/fd/%d", fd)
+}
+
+// procSubstFiles returns the files to give an external command so that
+// process substitution paths work in it. Each pipe ends up on the same
+// descriptor in the command as in the shell.
+func procSubstFiles(reg *commands.Registry) []*os.File {
+	var files []*os.File
+	for fd, file := range reg.ProcFiles {
+		for len(files) <= fd-3 {
+			files = append(files, nil)
+		}
+		files[fd-3] = file
+	}
+	return files
+}
+
+// finish closes the shell's ends of the process substitution pipes after
+// the command using them is done, and waits for their commands to exit.
+func (e *expander) finish() {
+	for _, p := range e.procs {
+		delete(e.reg.ProcFiles, int(p.file.Fd()))
+		p.file.Close()
+	}
+	for _, p := range e.procs {
+		<-p.done
+	}
+	e.procs = nil
+}
+
+// writeLiteral writes unquoted text to the current field. Glob characters in
+// it stay special.
+func (e *expander) writeLiteral(text string) {
+	e.flushPending()
+	e.cur.WriteString(text)
+	e.pat.WriteString(text)
+	if strings.ContainsAny(text, "*?[") {
+		e.glob = true
+	}
+}
+
+// writeQuoted writes text that came from a quoted context.
+func (e *expander) writeQuoted(text string) {
+	e.flushPending()
+	e.cur.WriteString(text)
+	if e.regex {
+		e.pat.WriteString(regexp.QuoteMeta(text))
+		return
+	}
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte(`*?[]\`, text[i]) >= 0 {
+			e.pat.WriteByte('\\')
+		}
+		e.pat.WriteByte(text[i])
+	}
+}
+
+// writeExpansion writes the result of an expansion to the current field.
+// Unquoted, it is split into fields on the characters of IFS.
+func (e *expander) writeExpansion(value string, quoted bool) {
+	switch {
+	case quoted:
+		e.writeQuoted(value)
+	case e.split:
+		e.splitFields(value)
+	default:
+		e.writeLiteral(value)
+	}
+}
+
+// splitFields writes an unquoted expansion, splitting it on IFS. A run of
+// IFS whitespace separates fields and is dropped at the start and end of a
+// word; any other IFS character ends a field, even an empty one, so with
+// IFS=: the value "a::b" gives "a", "" and "b".
+func (e *expander) splitFields(value string) {
+	ifs, ok := e.reg.Vars.Get("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	if ifs == "" {
+		e.writeLiteral(value)
+		return
+	}
+
+	start := 0
+	for i, r := range value {
+		if !strings.ContainsRune(ifs, r) {
+			continue
+		}
+		if start < i {
+			e.writeLiteral(value[start:i])
+		}
+		start = i + len(string(r))
+		if r == ' ' || r == '\t' || r == '\n' {
+			if e.cur.Len() > 0 || e.quoted {
+				e.pending = true
+			}
+			continue
+		}
+		e.pending = false
+		e.fields = append(e.fields, e.currentField())
+		e.resetField()
+	}
+	if start < len(value) {
+		e.writeLiteral(value[start:])
+	}
+}
+
+// flushPending ends the current field if IFS whitespace ended it.
+func (e *expander) flushPending() {
+	if e.pending {
+		e.endField()
+	}
+}
+
+// endField finishes the current field, dropping it if it is empty and
+// unquoted.
+func (e *expander) endField() {
+	if e.cur.Len() > 0 || e.quoted {
+		e.fields = append(e.fields, e.currentField())
+	}
+	e.resetField()
+}
+
+func (e *expander) currentField() field {
+	return field{text: e.cur.String(), pattern: e.pat.String(), glob: e.glob && e.split}
+}
+
+func (e *expander) resetField() {
+	e.cur.Reset()
+	e.pat.Reset()
+	e.quoted = false
+	e.glob = false
+	e.pending = false
+}
+
+// expandList expands a list of values such as $@ or ${arr[@]} to one field
+// per value. The first and last join up with the text around them, so
+// "x$@y" with parameters a and b gives "xa" and "by". Quoted, the star forms
+// $* and ${arr[*]} instead give a single field, joined by the first
+// character of IFS.
+func (e *expander) expandList(values []string, star, quoted bool) {
+	if len(values) == 0 {
+		e.emptyList = true
+	}
+	if star && quoted {
+		e.writeQuoted(strings.Join(values, ifsSeparator(e.reg)))
+		return
+	}
+	for n, value := range values {
+		if n > 0 {
+			e.endField()
+		}
+		if quoted {
+			e.writeQuoted(value)
+			e.quoted = true
+		} else {
+			e.writeExpansion(value, false)
+		}
+	}
+}
+
+// ifsSeparator returns the string that joins the elements of $* and
+// ${arr[*]}: the first character of IFS, or a space if IFS is unset.
+func ifsSeparator(reg *commands.Registry) string {
+	if ifs, ok := reg.Vars.Get("IFS"); ok {
+		return ifs[:min(len(ifs), 1)]
+	}
+	return " "
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
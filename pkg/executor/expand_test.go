@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+)
+
+func TestArithmeticBitwiseNot(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`echo $((~1))`, "-2"},
+		{`echo "$((~0))"`, "-1"},
+		{`echo $((1 + ~0))`, "0"},
+		{`((~0)) && echo true`, "true"},
+		{`for ((i=~0; i<1; i++)); do echo $i; done`, "-1\n0"},
+		{`s=hello; echo ${s:~0}`, "o"},
+		{`HOME=/home/u; echo ~ ~/x`, "/home/u /home/u/x"},
+	}
+	for _, tt := range tests {
+		out, err := execScript(t, tt.script, commands.NewRegistry())
+		if err != nil {
+			t.Errorf("%s: %v", tt.script, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out, "\n"); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestArithmeticBitwiseNotWithDirStack(t *testing.T) {
+	// ~0 and ~5 would name directory stack entries in a word
+	reg := commands.NewRegistry()
+	if _, err := execScript(t, "for d in / / / / / /; do pushd -n $d >/dev/null; done", reg); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`echo $((~0))`, "-1"},
+		{`echo $((~5))`, "-6"},
+		{`echo ~5`, "/"},
+	}
+	for _, tt := range tests {
+		out, err := execScript(t, tt.script, reg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.script, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out, "\n"); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+)
+
+// defaultFuncNest limits function recursion when FUNCNEST is not set, so a
+// runaway recursive function fails instead of exhausting memory.
+const defaultFuncNest = 1000
+
+// callFunction runs a shell function with args as its positional parameters
+// and a fresh scope for its local variables.
+func callFunction(fn *ast.FunctionNode, args []string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	limit := defaultFuncNest
+	if v, ok := reg.Vars.Get("FUNCNEST"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if reg.FuncDepth >= limit {
+		fmt.Fprintf(stderr, "%s: maximum function nesting level exceeded (%d)\n", fn.Name, limit)
+		return commands.ExitStatus(1)
+	}
+
+	savedArgs, savedLoops := reg.Positional, reg.LoopDepth
+	reg.Positional = args
+	reg.LoopDepth = 0 // break and continue cannot reach the caller's loops
+	reg.FuncDepth++
+	reg.Vars.PushScope()
+	defer func() {
+		reg.Vars.PopScope()
+		reg.FuncDepth--
+		reg.Positional, reg.LoopDepth = savedArgs, savedLoops
+	}()
+
+	err := Execute(fn.Body, reg, stdin, stdout, stderr)
+	if ret, ok := err.(*commands.FunctionReturn); ok {
+		if ret.Status == 0 {
+			return nil
+		}
+		return commands.ExitStatus(ret.Status)
+	}
+	return err
+}
+
+// positionalParam returns $n, or "" if there are fewer parameters.
+func positionalParam(n int, reg *commands.Registry) string {
+	if n == 0 {
+		return reg.Name
+	}
+	if n <= len(reg.Positional) {
+		return reg.Positional[n-1]
+	}
+	return ""
+}
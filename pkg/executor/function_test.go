@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+)
+
+const funcDef = `greet() {
+  local name=${1:-world} n=0
+  if [[ -n $name && ( $name == w* || ! -d /$name ) ]]; then echo "hi $name" | tr a-z A-Z; else echo 'bye'; fi
+  while ((n < 2)); do ((n++)); done > /dev/null
+  for w in a b; do case $w in a|b) echo $w ;; *) ;; esac; done
+  sleep 0 & :
+  { echo x; } && ( cd / ) || ! true
+}`
+
+const funcSource = `greet ()
+{
+    local name=${1:-world} n=0
+    if [[ -n $name && ( $name == w* || ! -d /$name ) ]]; then
+        echo "hi $name" | tr a-z A-Z
+    else
+        echo 'bye'
+    fi
+    while ((n < 2)); do
+        ((n++))
+    done > /dev/null
+    for w in a b; do
+        case $w in
+            a | b)
+                echo $w
+                ;;
+            *)
+                ;;
+        esac
+    done
+    sleep 0 &
+    :
+    { echo x; } && ( cd / ) || ! true
+}
+`
+
+func TestDeclareFunction(t *testing.T) {
+	reg := commands.NewRegistry()
+	if _, err := execScript(t, funcDef, reg); err != nil {
+		t.Fatalf("definition: %v", err)
+	}
+	out, err := execScript(t, "declare -f greet", reg)
+	if err != nil || out != funcSource {
+		t.Fatalf("declare -f greet = %q, %v; want %q", out, err, funcSource)
+	}
+	if out, _ := execScript(t, "declare -F greet", reg); out != "declare -f greet\n" {
+		t.Errorf("declare -F greet = %q", out)
+	}
+
+	// The printed definition defines the same function again
+	reg = commands.NewRegistry()
+	if _, err := execScript(t, funcSource, reg); err != nil {
+		t.Fatalf("printed definition: %v", err)
+	}
+	if out, _ := execScript(t, "declare -f greet", reg); out != funcSource {
+		t.Errorf("declare -f greet after reading it back = %q, want %q", out, funcSource)
+	}
+	// The job notice of the background command comes in between
+	out, _ = execScript(t, "greet", reg)
+	if !strings.HasPrefix(out, "HI WORLD\na\nb\n[1] ") || !strings.HasSuffix(out, "\nx\n") {
+		t.Errorf("greet = %q", out)
+	}
+}
+
+func TestUnsetFunctionCompletion(t *testing.T) {
+	reg := commands.NewRegistry()
+	for _, unset := range []string{"unset -f gosh_fn", "unset gosh_fn"} {
+		if _, err := execScript(t, "gosh_fn() { :; }", reg); err != nil {
+			t.Fatalf("definition: %v", err)
+		}
+		if got, _ := reg.Suggest("gosh_f"); len(got) != 1 {
+			t.Fatalf("completions of gosh_f after definition = %q", got)
+		}
+		if _, err := execScript(t, unset, reg); err != nil {
+			t.Fatalf("%s: %v", unset, err)
+		}
+		if got, ok := reg.Suggest("gosh_f"); ok {
+			t.Errorf("completions of gosh_f after %s = %q", unset, got)
+		}
+	}
+}
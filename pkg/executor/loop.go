@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/arith"
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/parser"
+)
+
+// executeWhile runs a while or until loop. Like the other loops, its status
+// is the status of the last body command it ran, or 0 if the body never ran.
+func executeWhile(n *ast.WhileNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	reg.LoopDepth++
+	defer func() { reg.LoopDepth-- }()
+
+	var status error
+	for !reg.ExitSignal {
+		err := executeCondition(n.Condition, reg, stdin, stdout, stderr)
+		if isControl(err) {
+			if stop, ret := unwind(err); stop {
+				return ret
+			}
+			continue
+		}
+		if (err == nil) == n.Until {
+			break
+		}
+
+		status = Execute(n.Body, reg, stdin, stdout, stderr)
+		if stop, ret := unwind(status); stop {
+			return ret
+		}
+	}
+	return loopStatus(status)
+}
+
+func executeFor(n *ast.ForNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	reg.LoopDepth++
+	defer func() { reg.LoopDepth-- }()
+
+	words := n.Words
+	if words == nil {
+		words = []*ast.Word{parser.ParseWord(`"$@"`)} // "for name; do" loops over the positional parameters
+	}
+
+	e := newExpander(reg, stdin, stdout, stderr)
+	defer e.finish()
+	values, err := e.expandWords(words)
+	if err != nil {
+		return expansionError(err, reg, stderr)
+	}
+
+	var status error
+	for _, value := range values {
+		if reg.ExitSignal {
+			break
+		}
+		if err := reg.Vars.Set(n.Name, value); err != nil {
+			fmt.Fprintln(stderr, err)
+			return commands.ExitStatus(1)
+		}
+
+		status = Execute(n.Body, reg, stdin, stdout, stderr)
+		if stop, ret := unwind(status); stop {
+			return ret
+		}
+	}
+	return loopStatus(status)
+}
+
+func executeArithFor(n *ast.ArithForNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	reg.LoopDepth++
+	defer func() { reg.LoopDepth-- }()
+
+	if _, err := evalArith(n.Init, reg, stdin, stderr); err != nil {
+		return err
+	}
+
+	var status error
+	for !reg.ExitSignal {
+		if n.Cond != "" {
+			v, err := evalArith(n.Cond, reg, stdin, stderr)
+			if err != nil {
+				return err
+			}
+			if v == 0 {
+				break
+			}
+		}
+
+		status = Execute(n.Body, reg, stdin, stdout, stderr)
+		if stop, ret := unwind(status); stop {
+			return ret
+		}
+
+		if _, err := evalArith(n.Step, reg, stdin, stderr); err != nil {
+			return err
+		}
+	}
+	return loopStatus(status)
+}
+
+// evalArith expands and evaluates an arithmetic expression, reporting errors
+// on stderr.
+func evalArith(expr string, reg *commands.Registry, stdin io.Reader, stderr io.Writer) (int64, error) {
+	expr, err := newExpander(reg, stdin, nil, stderr).expandText(expr, textArith)
+	if err != nil {
+		return 0, expansionError(err, reg, stderr)
+	}
+	v, err := arith.Eval(expr, reg.Vars)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", expr, err)
+		return 0, commands.ExitStatus(1)
+	}
+	return v, nil
+}
+
+// unwind handles a break or continue coming out of a loop body. It reports
+// whether the loop must stop, and if so what the loop should return: nil,
+// or a LoopControl that still targets an outer loop. A return from the
+// enclosing function passes straight through.
+func unwind(err error) (bool, error) {
+	lc, ok := err.(*commands.LoopControl)
+	if !ok {
+		return isControl(err), err
+	}
+	if lc.Levels > 1 {
+		return true, &commands.LoopControl{Continue: lc.Continue, Levels: lc.Levels - 1}
+	}
+	return !lc.Continue, nil
+}
+
+func loopStatus(err error) error {
+	if isControl(err) {
+		return nil
+	}
+	return err
+}
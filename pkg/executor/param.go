@@ -0,0 +1,525 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/arith"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/pattern"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// expandBraced expands the inside of "${...}": a parameter, possibly with a
+// subscript, followed by an optional operator. "${#param}" is the length of
+// a parameter, "${!name}" expands the parameter that name refers to,
+// "${!arr[@]}" lists the keys of an array and "${!prefix*}" the names of
+// the variables starting with prefix.
+func (e *expander) expandBraced(body string, quoted bool) {
+	whole := body
+	if len(body) > 1 && body[0] == '#' {
+		if name, sub, subscripted, rest := splitParam(body[1:]); name != "" && rest == "" {
+			p := e.param(name, sub, subscripted)
+			if !e.checkUnbound(body[1:], p, "") {
+				return
+			}
+			if p.list {
+				e.writeExpansion(strconv.Itoa(len(p.values)), quoted)
+			} else {
+				e.writeExpansion(strconv.Itoa(utf8.RuneCountInString(p.values[0])), quoted)
+			}
+			return
+		}
+	}
+
+	keys := false
+	if len(body) > 1 && body[0] == '!' {
+		body = body[1:]
+		if n := len(body); n > 1 && (body[n-1] == '*' || body[n-1] == '@') && vars.IsName(body[:n-1]) {
+			var names []string
+			for _, name := range e.reg.Vars.Names() {
+				if strings.HasPrefix(name, body[:n-1]) {
+					names = append(names, name)
+				}
+			}
+			e.expandList(names, body[n-1] == '*', quoted)
+			return
+		}
+		name, sub, subscripted, rest := splitParam(body)
+		if subscripted && (sub == "@" || sub == "*") {
+			keys = true
+		} else if name != "" {
+			// Indirection: the value of name is the parameter to expand
+			ref := e.param(name, sub, subscripted).values[0]
+			if refName, _, _, refRest := splitParam(ref); refName == "" || refRest != "" {
+				if e.err == nil {
+					e.err = fmt.Errorf("%s: invalid indirect expansion", ref)
+				}
+				return
+			}
+			body = ref + rest
+		}
+	}
+
+	name, sub, subscripted, op := splitParam(body)
+	if name == "" {
+		e.badSubstitution(whole)
+		return
+	}
+	var p paramValue
+	if keys {
+		v, _ := e.reg.Vars.Var(name)
+		p = paramValue{values: v.Keys(), list: true, star: sub == "*"}
+		p.set = len(p.values) > 0
+	} else {
+		p = e.param(name, sub, subscripted)
+	}
+
+	if !e.checkUnbound(strings.TrimSuffix(body, op), p, op) {
+		return
+	}
+	if op != "" && !e.applyOperator(&p, name, sub, subscripted, op, quoted) {
+		if e.err == nil {
+			e.badSubstitution(whole)
+		}
+		return
+	}
+	if p.list {
+		e.expandList(p.values, p.star, quoted)
+	} else {
+		e.writeExpansion(p.values[0], quoted)
+	}
+}
+
+// checkUnbound reports an error for set -u when p is not set, unless an
+// operator such as ${x-word} tests whether it is. Lists like $@ are
+// exempt. It returns false after an error.
+func (e *expander) checkUnbound(name string, p paramValue, op string) bool {
+	if !e.reg.Options.Nounset || p.set || p.list {
+		return true
+	}
+	if test := strings.TrimPrefix(op, ":"); test != "" && strings.IndexByte("-=?+", test[0]) >= 0 {
+		return true
+	}
+	if e.err == nil {
+		e.err = fatalError{fmt.Errorf("%s: unbound variable", name)}
+	}
+	return false
+}
+
+// paramValue is the value of a parameter during expansion. A list, like $@
+// or ${arr[@]}, has one value per element; anything else has exactly one.
+type paramValue struct {
+	values []string
+	list   bool
+	star   bool // $* or ${arr[*]}
+	set    bool
+}
+
+// param looks up a parameter for expandBraced.
+func (e *expander) param(name, sub string, subscripted bool) paramValue {
+	switch {
+	case subscripted && (sub == "@" || sub == "*"):
+		v, _ := e.reg.Vars.Var(name)
+		values := v.Values()
+		return paramValue{values: values, list: true, star: sub == "*", set: len(values) > 0}
+	case subscripted:
+		sub := e.opString(sub)
+		value, ok := e.reg.Vars.Get(name + "[" + sub + "]")
+		return paramValue{values: []string{value}, set: ok}
+	case name == "@" || name == "*":
+		return paramValue{values: e.reg.Positional, list: true, star: name == "*", set: len(e.reg.Positional) > 0}
+	}
+	value, ok := lookupParamSet(name, e.reg)
+	return paramValue{values: []string{value}, set: ok}
+}
+
+// applyOperator applies the operator part of "${param<op>}" to p. It
+// returns false if op is not a valid operator.
+func (e *expander) applyOperator(p *paramValue, name, sub string, subscripted bool, op string, quoted bool) bool {
+	null := !p.set || len(p.values) == 0 || (len(p.values) == 1 && p.values[0] == "")
+
+	// ${p-word}, ${p=word}, ${p?word} and ${p+word}; with a colon an empty
+	// value counts as unset
+	test := op
+	colon := strings.HasPrefix(op, ":") && len(op) > 1 && strings.IndexByte("-=?+", op[1]) >= 0
+	if colon {
+		test = op[1:]
+	}
+	if strings.IndexByte("-=?+", test[0]) >= 0 {
+		missing := !p.set
+		if colon {
+			missing = null
+		}
+		word := test[1:]
+		switch test[0] {
+		case '-':
+			if missing {
+				*p = e.opWord(word)
+			}
+		case '=':
+			if missing {
+				if !vars.IsName(name) || (subscripted && (sub == "@" || sub == "*")) {
+					e.err = fmt.Errorf("$%s: cannot assign in this way", name)
+					return false
+				}
+				value := e.opString(word)
+				target := name
+				if subscripted {
+					target += "[" + e.opString(sub) + "]"
+				}
+				if err := e.reg.Vars.Set(target, value); err != nil && e.err == nil {
+					e.err = err
+				}
+				*p = paramValue{values: []string{value}, set: true}
+			}
+		case '?':
+			if missing {
+				msg := e.opString(word)
+				if msg == "" {
+					msg = "parameter not set"
+					if colon {
+						msg = "parameter null or not set"
+					}
+				}
+				if e.err == nil {
+					e.err = fatalError{fmt.Errorf("%s: %s", name, msg)}
+				}
+				*p = paramValue{values: []string{""}}
+			}
+		case '+':
+			if missing {
+				*p = paramValue{values: []string{""}}
+			} else {
+				*p = e.opWord(word)
+			}
+		}
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(op, "##"), strings.HasPrefix(op, "#"):
+		longest := strings.HasPrefix(op, "##")
+		pat := e.opPattern(op[1:])
+		if longest {
+			pat = e.opPattern(op[2:])
+		}
+		p.each(func(s string) string {
+			if n := pattern.Prefix(pat, s, longest); n >= 0 {
+				return s[n:]
+			}
+			return s
+		})
+	case strings.HasPrefix(op, "%"):
+		longest := strings.HasPrefix(op, "%%")
+		pat := e.opPattern(op[1:])
+		if longest {
+			pat = e.opPattern(op[2:])
+		}
+		p.each(func(s string) string {
+			if n := pattern.Suffix(pat, s, longest); n >= 0 {
+				return s[:n]
+			}
+			return s
+		})
+	case strings.HasPrefix(op, "/"):
+		e.replace(p, op[1:])
+	case strings.HasPrefix(op, "^"), strings.HasPrefix(op, ","):
+		all := len(op) > 1 && op[1] == op[0]
+		pat := op[1:]
+		if all {
+			pat = op[2:]
+		}
+		pat = e.opPattern(pat)
+		convert := unicode.ToUpper
+		if op[0] == ',' {
+			convert = unicode.ToLower
+		}
+		p.each(func(s string) string { return changeCase(s, pat, all, convert) })
+	case op[0] == ':':
+		return e.substring(p, name, subscripted, op[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// replace implements "${param/pat/rep}". The pattern may start with '/' to
+// replace every match, '#' to match at the start or '%' at the end.
+func (e *expander) replace(p *paramValue, op string) {
+	mode := byte(0)
+	if op != "" && strings.IndexByte("/#%", op[0]) >= 0 {
+		mode = op[0]
+		op = op[1:]
+	}
+	patWord, repWord, _ := cutUnquoted(op, '/')
+	pat, rep := e.opPattern(patWord), e.opString(repWord)
+	p.each(func(s string) string {
+		switch {
+		case pat == "" && mode != '#' && mode != '%':
+			return s
+		case mode == '#':
+			if n := pattern.Prefix(pat, s, true); n >= 0 {
+				return rep + s[n:]
+			}
+			return s
+		case mode == '%':
+			if n := pattern.Suffix(pat, s, true); n >= 0 {
+				return s[:n] + rep
+			}
+			return s
+		}
+		return pattern.Replace(s, pat, rep, mode == '/')
+	})
+}
+
+// substring implements "${param:offset}" and "${param:offset:length}". For
+// strings they count characters and for lists elements; a negative offset
+// counts back from the end, and a negative length gives the end as an
+// offset from the end. The list $@ starts with $0.
+func (e *expander) substring(p *paramValue, name string, subscripted bool, op string) bool {
+	offWord, lenWord, hasLen := cutUnquoted(op, ':')
+	if strings.TrimSpace(offWord) == "" {
+		return false
+	}
+	offset, ok := e.opArith(offWord)
+	if !ok {
+		return true
+	}
+	var length int64
+	if hasLen {
+		if length, ok = e.opArith(lenWord); !ok {
+			return true
+		}
+	}
+
+	if p.list {
+		values := p.values
+		if !subscripted && (name == "@" || name == "*") {
+			values = append([]string{positionalParam(0, e.reg)}, values...)
+		}
+		start, end, ok := sliceBounds(int64(len(values)), offset, length, hasLen)
+		if !ok {
+			if length < 0 && e.err == nil {
+				e.err = fmt.Errorf("%s: substring expression < 0", lenWord)
+			}
+			p.values = nil
+			return true
+		}
+		p.values = values[start:end]
+		return true
+	}
+
+	runes := []rune(p.values[0])
+	start, end, ok := sliceBounds(int64(len(runes)), offset, length, hasLen)
+	if !ok {
+		if hasLen && length < 0 && e.err == nil {
+			e.err = fmt.Errorf("%s: substring expression < 0", lenWord)
+		}
+		p.values[0] = ""
+		return true
+	}
+	p.values[0] = string(runes[start:end])
+	return true
+}
+
+// sliceBounds works out the part of a sequence of n items that an offset and
+// length select, as for "${param:offset:length}".
+func sliceBounds(n, offset, length int64, hasLen bool) (int64, int64, bool) {
+	if offset < 0 {
+		offset += n
+	}
+	if offset < 0 || offset > n {
+		return 0, 0, false
+	}
+	end := n
+	if hasLen {
+		end = offset + length
+		if length < 0 {
+			end = n + length
+		}
+		if end < offset {
+			return 0, 0, false
+		}
+		end = min(end, n)
+	}
+	return offset, end, true
+}
+
+// each replaces every value of p with the result of fn.
+func (p *paramValue) each(fn func(string) string) {
+	values := make([]string, len(p.values))
+	for i, v := range p.values {
+		values[i] = fn(v)
+	}
+	p.values = values
+}
+
+// opWord expands the word of an operator such as ${x:-word}.
+func (e *expander) opWord(word string) paramValue {
+	return paramValue{values: []string{e.opString(word)}, set: true}
+}
+
+// opString expands part of an operator to a single string, keeping the first
+// error.
+func (e *expander) opString(word string) string {
+	s, err := e.nested().expandText(word, textString)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return s
+}
+
+// opPattern expands the pattern of an operator such as ${x#pattern}.
+func (e *expander) opPattern(word string) string {
+	s, err := e.nested().expandText(word, textPattern)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return s
+}
+
+// opArith expands and evaluates an arithmetic expression in an operator.
+func (e *expander) opArith(expr string) (int64, bool) {
+	expr, err := e.nested().expandText(expr, textArith)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return 0, false
+	}
+	v, err := arith.Eval(expr, e.reg.Vars)
+	if err != nil {
+		if e.err == nil {
+			e.err = fmt.Errorf("%s: %v", expr, err)
+		}
+		return 0, false
+	}
+	return v, true
+}
+
+// changeCase converts the first character of s, or every character if all
+// is set, that matches pat. An empty pattern matches any character.
+func changeCase(s, pat string, all bool, convert func(rune) rune) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if pat == "" || pattern.Match(pat, string(r)) {
+			runes[i] = convert(r)
+		}
+		if !all {
+			break
+		}
+	}
+	return string(runes)
+}
+
+// splitParam splits the start of body into a parameter name, with its
+// subscript if it has one, and returns the rest of body. The name is empty
+// if body does not start with a parameter.
+func splitParam(body string) (name, sub string, subscripted bool, rest string) {
+	if body == "" {
+		return "", "", false, body
+	}
+	switch c := body[0]; {
+	case strings.IndexByte("?$#@*-!", c) >= 0:
+		return body[:1], "", false, body[1:]
+	case c >= '0' && c <= '9':
+		i := 1
+		for i < len(body) && body[i] >= '0' && body[i] <= '9' {
+			i++
+		}
+		return body[:i], "", false, body[i:]
+	case !isNameStart(c):
+		return "", "", false, body
+	}
+
+	i := 1
+	for i < len(body) && isNameChar(body[i]) {
+		i++
+	}
+	if i == len(body) || body[i] != '[' {
+		return body[:i], "", false, body[i:]
+	}
+	depth := 0
+	for j := i; j < len(body); j++ {
+		switch body[j] {
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				return body[:i], body[i+1 : j], true, body[j+1:]
+			}
+		}
+	}
+	return "", "", false, body
+}
+
+// cutUnquoted splits s around the first sep that is not quoted, escaped or
+// inside a nested ${...}.
+func cutUnquoted(s string, sep byte) (string, string, bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == sep && depth == 0:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func (e *expander) badSubstitution(body string) {
+	if e.err == nil {
+		e.err = fmt.Errorf("${%s}: bad substitution", body)
+	}
+}
+
+// isParamName reports whether name is a variable name, a positional
+// parameter number or a special parameter.
+func isParamName(name string) bool {
+	if len(name) == 1 && strings.IndexByte("?$#@*-", name[0]) >= 0 {
+		return true
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return true
+	}
+	return vars.IsName(name)
+}
+
+// lookupParamSet returns the value of a variable or special parameter and
+// whether it is set.
+func lookupParamSet(name string, reg *commands.Registry) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(reg.LastStatus), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "-":
+		return reg.Flags(), true
+	case "#":
+		return strconv.Itoa(len(reg.Positional)), true
+	case "*":
+		return strings.Join(reg.Positional, ifsSeparator(reg)), len(reg.Positional) > 0
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 {
+		return positionalParam(n, reg), n <= len(reg.Positional)
+	}
+	return reg.Vars.Get(name)
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
+	"github.com/codecrafters-io/shell-starter-go/pkg/parser"
+)
+
+const paramSetup = `
+path=/usr/local/lib/file.tar.gz
+s="hello world"
+u="HELLO World"
+arr=(one two three four)
+PARAMTEST_A=1 PARAMTEST_B=2
+`
+
+// execScript parses and executes script, returning its output.
+func execScript(t *testing.T, script string, reg *commands.Registry) (string, error) {
+	t.Helper()
+	p := parser.New(lexer.New(script))
+	node := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("%q: %s", script, strings.Join(errs, "; "))
+	}
+	var stdout, stderr bytes.Buffer
+	err := Execute(node, reg, nil, &stdout, &stderr)
+	if stderr.Len() > 0 {
+		t.Errorf("%q: %s", script, stderr.String())
+	}
+	return stdout.String(), err
+}
+
+func TestParameterOperators(t *testing.T) {
+	reg := commands.NewRegistry()
+	if _, err := execScript(t, paramSetup, reg); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	tests := []struct {
+		word string
+		want string
+	}{
+		// Removing a prefix or suffix
+		{"${path#*/}", "usr/local/lib/file.tar.gz"},
+		{"${path##*/}", "file.tar.gz"},
+		{"${path%.*}", "/usr/local/lib/file.tar"},
+		{"${path%%.*}", "/usr/local/lib/file"},
+		{"${path#nomatch}", "/usr/local/lib/file.tar.gz"},
+		{"${path%/*/*}", "/usr/local"},
+		{"${arr[@]#t}", "one wo hree four"},
+
+		// Replacing a pattern
+		{"${s/o/0}", "hell0 world"},
+		{"${s//o/0}", "hell0 w0rld"},
+		{"${s//o}", "hell wrld"},
+		{"${s/[lo]*/X}", "heX"},
+		{"${s/#hello/bye}", "bye world"},
+		{"${s/%world/there}", "hello there"},
+		{"${s/#world/x}", "hello world"},
+		{"${arr[@]//e/E}", "onE two thrEE four"},
+
+		// Substrings
+		{"${s:6}", "world"},
+		{"${s:0:5}", "hello"},
+		{"${s: -5}", "world"},
+		{"${s: -5:2}", "wo"},
+		{"${s:(-3)}", "rld"},
+		{"${s:3:-3}", "lo wo"},
+		{"${s:20}", ""},
+		{"${s: -20}", ""},
+		{"${arr[@]:1:2}", "two three"},
+		{"${arr[@]: -1}", "four"},
+
+		// Case conversion
+		{"${s^}", "Hello world"},
+		{"${s^^}", "HELLO WORLD"},
+		{"${s^^[lo]}", "heLLO wOrLd"},
+		{"${u,}", "hELLO World"},
+		{"${u,,}", "hello world"},
+		{"${arr[@]^}", "One Two Three Four"},
+
+		// Names by prefix and lengths
+		{"${!PARAMTEST_*}", "PARAMTEST_A PARAMTEST_B"},
+		{"${!PARAMTEST_@}", "PARAMTEST_A PARAMTEST_B"},
+		{"${#s}", "11"},
+		{"${#arr[@]}", "4"},
+	}
+	for _, tt := range tests {
+		out, err := execScript(t, `echo "`+tt.word+`"`, reg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.word, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out, "\n"); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
+)
+
+// defaultTimeFormat is how "time" reports when TIMEFORMAT is unset.
+const defaultTimeFormat = "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS"
+
+// posixTimeFormat is the format of "time -p".
+const posixTimeFormat = "real %2R\nuser %2U\nsys %2S"
+
+// executePipe runs a pipeline, applying "time" and "!" to it as a whole.
+func executePipe(n *ast.PipeNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	var start time.Time
+	var before usage
+	if n.Time {
+		start, before = time.Now(), getUsage()
+	}
+
+	// The status of a negated pipeline is tested, so set -e ignores it
+	if n.Negate {
+		reg.CondDepth++
+	}
+	err := runPipe(n, reg, stdin, stdout, stderr)
+	if n.Negate {
+		reg.CondDepth--
+	}
+
+	if n.Time {
+		after := getUsage()
+		format, ok := reg.Vars.Get("TIMEFORMAT")
+		if !ok {
+			format = defaultTimeFormat
+		}
+		if n.TimePOSIX {
+			format = posixTimeFormat
+		}
+		if format != "" {
+			fmt.Fprintln(stderr, formatTime(format, time.Since(start), after.user-before.user, after.sys-before.sys))
+		}
+	}
+
+	if n.Negate && !isControl(err) {
+		if exitStatus(err) == 0 {
+			return commands.ExitStatus(1)
+		}
+		return nil
+	}
+	return err
+}
+
+// runPipe runs the stages of a pipeline, each reading the output of the one
+// before. The stages run concurrently, each in its own copy of the shell
+// state, so "echo x | read v" leaves v alone in the current shell. Only a
+// pipeline of one command, as in "! cmd" or "time cmd", runs it in the
+// current shell. The status of the pipeline is that of the last stage or,
+// with pipefail, of the last stage that failed. PIPESTATUS gets the status
+// of every stage.
+func runPipe(n *ast.PipeNode, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(n.Stages) == 0 {
+		return nil
+	}
+	errs := make([]error, len(n.Stages))
+	statuses := make([]int, len(n.Stages))
+
+	var wg sync.WaitGroup
+	in := stdin
+	for i, stage := range n.Stages[:len(n.Stages)-1] {
+		r, w, err := os.Pipe()
+		if err != nil {
+			errs[i] = err
+			statuses[i] = 1
+			break
+		}
+		sub := reg.NewSubshell()
+		wg.Add(1)
+		go func(i int, stage ast.Node, in io.Reader) {
+			defer wg.Done()
+			err := Execute(stage, sub, in, w, stderr)
+			statuses[i] = exitStatus(err)
+			if sub.ExitSignal {
+				statuses[i] = sub.ExitCode
+			}
+			// Closing both ends lets the next stage see EOF, and the stage
+			// before get a broken pipe if it is still writing
+			w.Close()
+			closeReader(in, stdin)
+		}(i, stage, in)
+		in = r
+	}
+
+	last := len(n.Stages) - 1
+	if last == 0 {
+		errs[last] = Execute(n.Stages[last], reg, in, stdout, stderr)
+		statuses[last] = exitStatus(errs[last])
+	} else {
+		sub := reg.NewSubshell()
+		statuses[last] = exitStatus(Execute(n.Stages[last], sub, in, stdout, stderr))
+		if sub.ExitSignal {
+			statuses[last] = sub.ExitCode
+		}
+		if statuses[last] != 0 {
+			errs[last] = commands.ExitStatus(statuses[last])
+		}
+	}
+	closeReader(in, stdin)
+	wg.Wait()
+
+	elems := make([]vars.Element, len(statuses))
+	for i, status := range statuses {
+		elems[i] = vars.Element{Value: strconv.Itoa(status)}
+	}
+	reg.Vars.SetArray("PIPESTATUS", elems, false)
+
+	if reg.Options.Pipefail && statuses[last] == 0 && !isControl(errs[last]) {
+		for i := last - 1; i >= 0; i-- {
+			if statuses[i] != 0 {
+				return commands.ExitStatus(statuses[i])
+			}
+		}
+	}
+	return errs[last]
+}
+
+// closeReader closes the read end of a pipe between two stages, but not the
+// pipeline's own input.
+func closeReader(r, stdin io.Reader) {
+	if f, ok := r.(*os.File); ok && r != stdin {
+		f.Close()
+	}
+}
+
+// setPipeStatus sets PIPESTATUS after a command that is not part of a
+// pipeline.
+func setPipeStatus(reg *commands.Registry, status int) {
+	reg.Vars.SetArray("PIPESTATUS", []vars.Element{{Value: strconv.Itoa(status)}}, false)
+}
+
+// usage is the CPU time used by the shell and the child processes it has
+// waited for.
+type usage struct {
+	user, sys time.Duration
+}
+
+func getUsage() usage {
+	var u usage
+	for _, who := range []int{syscall.RUSAGE_SELF, syscall.RUSAGE_CHILDREN} {
+		var ru syscall.Rusage
+		if syscall.Getrusage(who, &ru) == nil {
+			u.user += time.Duration(ru.Utime.Nano())
+			u.sys += time.Duration(ru.Stime.Nano())
+		}
+	}
+	return u
+}
+
+// formatTime expands a TIMEFORMAT string. "%R", "%U" and "%S" are the real,
+// user and system time in seconds and "%P" the CPU percentage. An optional
+// digit after the '%' gives the number of decimals, 3 at most, and an 'l'
+// gives the time in the "1m2.345s" form.
+func formatTime(format string, real, user, sys time.Duration) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if format[i] == 'P' {
+			percent := 0.0
+			if real > 0 {
+				percent = float64(user+sys) / float64(real) * 100
+			}
+			b.WriteString(strconv.FormatFloat(percent, 'f', 2, 64))
+			continue
+		}
+
+		start := i - 1
+		precision := 3
+		if format[i] >= '0' && format[i] <= '9' {
+			precision = min(int(format[i]-'0'), 3)
+			i++
+		}
+		long := false
+		if i < len(format) && format[i] == 'l' {
+			long = true
+			i++
+		}
+		var d time.Duration
+		switch {
+		case i >= len(format):
+			b.WriteString(format[start:])
+			continue
+		case format[i] == 'R':
+			d = real
+		case format[i] == 'U':
+			d = user
+		case format[i] == 'S':
+			d = sys
+		default:
+			b.WriteString(format[start : i+1])
+			continue
+		}
+
+		if long {
+			minutes := int64(d / time.Minute)
+			seconds := (d % time.Minute).Seconds()
+			fmt.Fprintf(&b, "%dm%.*fs", minutes, precision, seconds)
+		} else {
+			fmt.Fprintf(&b, "%.*f", precision, d.Seconds())
+		}
+	}
+	return b.String()
+}
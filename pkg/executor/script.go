@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
+	"github.com/codecrafters-io/shell-starter-go/pkg/parser"
+)
+
+func init() {
+	commands.ScriptRunner = runScript
+}
+
+// RunScript reads commands from src and runs each one as soon as it is
+// complete, the way a shell runs a script: a command on a later line can
+// depend on what earlier ones did. name is the script's name for error
+// messages. It returns the status of the last command, or the status passed
+// to exit, or 2 after a syntax error.
+func RunScript(src io.Reader, name string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) int {
+	err := runScript(src, name, reg, stdin, stdout, stderr)
+	switch {
+	case reg.ExitSignal:
+		return reg.ExitCode
+	case err != nil:
+		return exitStatus(err)
+	}
+	return reg.LastStatus
+}
+
+// runScriptFile runs an executable file that the system cannot run itself as
+// a shell script, in a subshell with args[1:] as its positional parameters.
+func runScriptFile(path string, args []string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+		return commands.ExitStatus(126)
+	}
+	defer f.Close()
+
+	sub := reg.NewSubshell()
+	sub.Name = args[0]
+	sub.Positional = args[1:]
+	sub.LoopDepth, sub.FuncDepth, sub.SourceDepth, sub.CondDepth = 0, 0, 0, 0
+	if status := RunScript(f, args[0], sub, stdin, stdout, stderr); status != 0 {
+		return commands.ExitStatus(status)
+	}
+	return nil
+}
+
+// runScript runs the commands read from src in reg. It stops early after a
+// syntax error, which it reports as status 2, after exit, or at a return
+// that is not inside a function, which it passes on to the caller.
+func runScript(src io.Reader, name string, reg *commands.Registry, stdin io.Reader, stdout, stderr io.Writer) error {
+	var reader lineReader = bufio.NewReader(src)
+	if src == stdin {
+		// The commands read the rest of the input themselves, as in
+		// "cat script | gosh", so take no more of it than each line
+		reader = unbufferedReader{src}
+	}
+
+	// pending holds the lines of a command that is not complete yet, and
+	// start is the line it started on
+	var pending strings.Builder
+	lineNo, start := 0, 0
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line == "" && readErr != nil && pending.Len() == 0 {
+			return nil
+		}
+		lineNo++
+		if pending.Len() == 0 {
+			start = lineNo
+		}
+		pending.WriteString(line)
+
+		p := parser.New(lexer.New(pending.String()))
+		p.SetAliases(reg.Aliases)
+		program := p.Parse()
+		if p.Incomplete() && readErr == nil {
+			continue
+		}
+		pending.Reset()
+
+		if errs := p.Errors(); len(errs) > 0 {
+			fmt.Fprintf(stderr, "%s: line %d: %s\n", name, start, errs[0])
+			return commands.ExitStatus(2)
+		}
+		err := Execute(program, reg, stdin, stdout, stderr)
+		if reg.ExitSignal {
+			return nil
+		}
+		if _, ok := err.(*commands.FunctionReturn); ok {
+			return err
+		}
+	}
+}
+
+// lineReader reads a script line by line.
+type lineReader interface {
+	ReadString(delim byte) (string, error)
+}
+
+// unbufferedReader reads one byte at a time, so that it never takes input
+// past the end of the line it returns.
+type unbufferedReader struct {
+	r io.Reader
+}
+
+func (u unbufferedReader) ReadString(delim byte) (string, error) {
+	var line []byte
+	var b [1]byte
+	for {
+		n, err := u.r.Read(b[:])
+		if n == 1 {
+			line = append(line, b[0])
+			if b[0] == delim {
+				return string(line), nil
+			}
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/commands"
+)
+
+// A script read from the shell's stdin leaves the lines after each command
+// for the commands to read.
+func TestRunScriptSharedStdin(t *testing.T) {
+	in := strings.NewReader("read line\nhello\necho \"got $line\"\nread -r a b\n1 2\necho $b $a\n")
+	var stdout, stderr bytes.Buffer
+	status := RunScript(in, "gosh", commands.NewRegistry(), in, &stdout, &stderr)
+	if status != 0 || stderr.Len() > 0 {
+		t.Errorf("status %d: %s", status, stderr.String())
+	}
+	if want := "got hello\n2 1\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
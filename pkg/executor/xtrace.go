@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// trace prints a command for set -x: the expanded words, quoted where they
+// need it, after the expanded value of PS4.
+func (e *expander) trace(words ...string) {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = traceQuote(word)
+	}
+	e.traceLine(strings.Join(quoted, " "))
+}
+
+// traceAssignment prints an assignment for set -x.
+func (e *expander) traceAssignment(a assignment) {
+	if a.array {
+		e.traceLine(a.String())
+		return
+	}
+	op := "="
+	if a.appending {
+		op = "+="
+	}
+	e.traceLine(a.name + op + traceQuote(a.value))
+}
+
+// traceLine prints a line of set -x output after PS4.
+func (e *expander) traceLine(line string) {
+	ps4, ok := e.reg.Vars.Get("PS4")
+	if !ok {
+		ps4 = "+ "
+	}
+	if expanded, err := e.nested().expandText(ps4, textString); err == nil {
+		ps4 = expanded
+	}
+	fmt.Fprintln(e.stderr, ps4+line)
+}
+
+// traceQuote quotes a word for set -x output when it contains characters
+// that are special to the shell, so the line can be run again.
+func traceQuote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	safe := true
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if !isNameChar(c) && strings.IndexByte("-+=./:,@%^", c) < 0 {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -39,6 +39,14 @@ func (l *Lexer) peekChar() byte {
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
+	// A '#' at the start of a word starts a comment, which runs to the end
+	// of the line. This also skips a script's "#!" line.
+	if l.ch == '#' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	}
+
 	var tok token.Token
 
 	if l.ch == 0 {
@@ -79,12 +87,34 @@ func (l *Lexer) NextToken() token.Token {
         }
     }
 
-	if l.ch == ';' {
-		tok = token.Token{Type: token.SEMICOLON, Literal: ";"}
+	if l.ch == '\n' {
+		tok = token.Token{Type: token.NEWLINE, Literal: "\n"}
 		l.readChar()
 		return tok
 	}
 
+	if l.ch == ';' {
+		l.readChar()
+		switch {
+		case l.ch == ';' && l.peekChar() == '&':
+			l.readChar()
+			l.readChar()
+			return token.Token{Type: token.DSEMI_AND, Literal: ";;&"}
+		case l.ch == ';':
+			l.readChar()
+			return token.Token{Type: token.DSEMI, Literal: ";;"}
+		case l.ch == '&':
+			l.readChar()
+			return token.Token{Type: token.SEMI_AND, Literal: ";&"}
+		}
+		return token.Token{Type: token.SEMICOLON, Literal: ";"}
+	}
+
+	// <(...) and >(...) are process substitutions, which are words
+	if isRedirectStart(l.ch) && l.peekChar() == '(' {
+		return l.readWordToken()
+	}
+
 	if isRedirectStart(l.ch) || (isDigit(l.ch) && (l.peekChar() == '>')) {
 		literal := l.readRedirect()
 		// Double check it wasn't just a number like "123"
@@ -101,19 +131,77 @@ func (l *Lexer) NextToken() token.Token {
 		return tok
 	}
 
-	tok.Literal = l.readWord()
+	if l.ch == '(' && l.peekChar() == '(' {
+		return l.readArith()
+	}
+
+	if l.ch == '(' || l.ch == ')' {
+		tok = token.Token{Type: token.TokenType(string(l.ch)), Literal: string(l.ch)}
+		l.readChar()
+		return tok
+	}
 
+	return l.readWordToken()
+}
+
+// readWordToken reads a word and returns it as a WORD or keyword token.
+func (l *Lexer) readWordToken() token.Token {
+	var tok token.Token
+	literal, unterminated := l.readWord()
+	if unterminated != 0 {
+		// The word ran into EOF inside a quote or right after a backslash.
+		// The literal records what is missing so the parser can report it.
+		tok.Type = token.ILLEGAL
+		tok.Literal = string(unterminated)
+		return tok
+	}
+
+	if literal == "" {
+		// Nothing but a line continuation
+		return l.NextToken()
+	}
+
+	tok.Literal = literal
 	tok.Type = token.LookupIdent(tok.Literal)
 	return tok
 }
 
+// readArith reads "((expr))" and returns an ARITH token holding expr.
+func (l *Lexer) readArith() token.Token {
+	l.readChar()
+	l.readChar()
+	start := l.position
+	depth := 0
+	for l.ch != 0 {
+		switch l.ch {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 && l.peekChar() == ')' {
+				expr := l.input[start:l.position]
+				l.readChar()
+				l.readChar()
+				return token.Token{Type: token.ARITH, Literal: expr}
+			}
+			depth--
+		}
+		l.readChar()
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: "))"}
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\n' {
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
-func (l *Lexer) readWord() string {
+// readWord reads a single word. Quotes and backslashes are kept in the
+// literal: they are only removed during expansion, which needs to know which
+// parts of the word were quoted. If the input ends while a quote is still
+// open or right after a backslash, the second return value is the offending
+// character (', " or \), otherwise it is 0.
+func (l *Lexer) readWord() (string, byte) {
 	var current strings.Builder
 	inSingle := false
 	inDouble := false
@@ -122,6 +210,16 @@ func (l *Lexer) readWord() string {
 	for l.ch != 0 {
 		ch := l.ch
 
+		// A process substitution is copied whole, like $(...)
+		if isRedirectStart(ch) && !inSingle && !inDouble && !escaped && l.peekChar() == '(' {
+			current.WriteByte(ch)
+			l.readChar()
+			if missing := l.readNested(&current); missing != 0 {
+				return current.String(), missing
+			}
+			continue
+		}
+
 		if !inSingle && !inDouble && !escaped {
 			//if delimeter , complete the word eg echo hello; ls --> break at hello
 			if token.IsDelimiter(ch) {
@@ -130,45 +228,119 @@ func (l *Lexer) readWord() string {
 		}
 
 		if escaped {
-			current.WriteByte(ch)
+			// backslash-newline is a line continuation and is removed entirely
+			if ch != '\n' {
+				current.WriteByte('\\')
+				current.WriteByte(ch)
+			}
 			escaped = false
 			l.readChar()
 			continue
 		}
 
-		if ch == '\\' {
-			if inSingle {
-				current.WriteByte(ch)
-			} else if inDouble {
-				peek := l.peekChar()
-				if peek == '"' || peek == '\\' || peek == '$' || peek == '`' || peek == '\n' {
-					escaped = true
-				} else {
-					current.WriteByte(ch)
+		if ch == '\\' && !inSingle {
+			escaped = true
+			l.readChar()
+			continue
+		}
+
+		// $(...) and ${...} may contain delimiters, so copy them whole
+		if ch == '$' && !inSingle && (l.peekChar() == '(' || l.peekChar() == '{') {
+			current.WriteByte(ch)
+			l.readChar()
+			if missing := l.readNested(&current); missing != 0 {
+				return current.String(), missing
+			}
+			continue
+		}
+
+		// `...` may contain delimiters too
+		if ch == '`' && !inSingle {
+			current.WriteByte(ch)
+			l.readChar()
+			for l.ch != '`' {
+				if l.ch == 0 {
+					return current.String(), '`'
 				}
-			} else {
-				escaped = true
+				if l.ch == '\\' && l.peekChar() != 0 {
+					current.WriteByte(l.ch)
+					l.readChar()
+				}
+				current.WriteByte(l.ch)
+				l.readChar()
 			}
+			current.WriteByte(ch)
 			l.readChar()
 			continue
 		}
 
 		if ch == '\'' && !inDouble {
 			inSingle = !inSingle
-			l.readChar()
-			continue
 		}
 		if ch == '"' && !inSingle {
 			inDouble = !inDouble
-			l.readChar()
-			continue
 		}
 
 		current.WriteByte(ch)
 		l.readChar()
 	}
 
-	return current.String()
+	switch {
+	case inSingle:
+		return current.String(), '\''
+	case inDouble:
+		return current.String(), '"'
+	case escaped:
+		return current.String(), '\\'
+	}
+	return current.String(), 0
+}
+
+// readNested copies a parenthesised or braced section starting at the current
+// '(' or '{' into current, including nested pairs and quoted text. It returns
+// the missing closing character if the input ends first, otherwise 0.
+func (l *Lexer) readNested(current *strings.Builder) byte {
+	open := l.ch
+	close := byte(')')
+	if open == '{' {
+		close = '}'
+	}
+
+	depth := 0
+	var quote byte
+	for l.ch != 0 {
+		ch := l.ch
+		current.WriteByte(ch)
+		l.readChar()
+
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' && l.ch != 0 {
+				current.WriteByte(l.ch)
+				l.readChar()
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\\':
+			if l.ch != 0 {
+				current.WriteByte(l.ch)
+				l.readChar()
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == open:
+			depth++
+		case ch == close:
+			depth--
+			if depth == 0 {
+				return 0
+			}
+		}
+	}
+	if quote != 0 {
+		return quote
+	}
+	return close
 }
 
 func (l *Lexer) readRedirect() string {
@@ -181,7 +353,7 @@ func (l *Lexer) readRedirect() string {
 	if l.ch == '>' {
 		res.WriteByte(l.ch)
 		l.readChar()
-		if l.ch == '>' {
+		if l.ch == '>' || l.ch == '|' {
 			res.WriteByte(l.ch)
 			l.readChar()
 		}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
+	"github.com/codecrafters-io/shell-starter-go/pkg/token"
+)
+
+// aliasInfo records how a token relates to alias expansion.
+type aliasInfo struct {
+	// expanding lists the aliases whose values the token came from. They
+	// are not expanded again, so "alias ls='ls -F'" does not recurse.
+	expanding []string
+	// check is set on the word after an alias whose value ends in a blank,
+	// which is checked for an alias as well, as in "alias sudo='sudo '".
+	check bool
+}
+
+// queuedToken is a token waiting to be read before the lexer's next one.
+type queuedToken struct {
+	tok  token.Token
+	info aliasInfo
+}
+
+// SetAliases makes the parser expand the given aliases, by name, in
+// command position.
+func (p *Parser) SetAliases(aliases map[string]string) {
+	p.aliases = aliases
+}
+
+// expandAlias replaces the current token with the tokens of its alias's
+// value, repeating while the command name is an alias. It reports whether
+// anything was expanded.
+func (p *Parser) expandAlias() bool {
+	expanded := false
+	for p.curToken.Type == token.WORD {
+		name := p.curToken.Literal
+		value, ok := p.aliases[name]
+		if !ok || slices.Contains(p.curInfo.expanding, name) {
+			break
+		}
+		info := aliasInfo{expanding: append(slices.Clip(p.curInfo.expanding), name)}
+
+		var queue []queuedToken
+		l := lexer.New(value)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			queue = append(queue, queuedToken{tok: tok, info: info})
+		}
+		next := queuedToken{tok: p.peekToken, info: p.peekInfo}
+		if strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t") {
+			next.info.check = true
+		}
+		p.queue = append(append(queue, next), p.queue...)
+
+		// Move the first two queued tokens into curToken and peekToken
+		p.nextToken()
+		p.nextToken()
+		expanded = true
+	}
+	return expanded
+}
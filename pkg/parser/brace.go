@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExpandBraces performs brace expansion on the raw text of a word and
+// returns the words it expands to, in order. "a{b,c}d" gives "abd" and
+// "acd", and "{1..9..2}" or "{a..e}" a sequence; numbers with a leading
+// zero are padded to the same width. Braces that are quoted, escaped or
+// part of "${...}" do not count, and a word without a valid brace
+// expression is returned unchanged, so "{" and "}" on their own stay
+// literal.
+func ExpandBraces(raw string) []string {
+	if !strings.Contains(raw, "{") {
+		return []string{raw}
+	}
+	for start := 0; ; {
+		open := indexUnquoted(raw, start, '{')
+		if open < 0 {
+			return []string{raw}
+		}
+		end, commas := braceEnd(raw, open)
+		if end < 0 {
+			start = open + 1
+			continue
+		}
+
+		var alts []string
+		if len(commas) > 0 {
+			from := open + 1
+			for _, comma := range append(commas, end) {
+				alts = append(alts, ExpandBraces(raw[from:comma])...)
+				from = comma + 1
+			}
+		} else if seq, ok := braceSequence(raw[open+1 : end]); ok {
+			alts = seq
+		} else {
+			start = open + 1
+			continue
+		}
+
+		var words []string
+		for _, alt := range alts {
+			for _, post := range ExpandBraces(raw[end+1:]) {
+				words = append(words, raw[:open]+alt+post)
+			}
+		}
+		return words
+	}
+}
+
+// braceEnd returns the index of the '}' that closes the '{' at raw[open],
+// and the indexes of the commas between them that are not inside nested
+// braces. The index is -1 if the brace is not closed.
+func braceEnd(raw string, open int) (int, []int) {
+	var commas []int
+	depth := 0
+	for i := open + 1; i < len(raw); i++ {
+		if end := skipQuoted(raw, i); end > i {
+			i = end
+			continue
+		}
+		switch raw[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i, commas
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// indexUnquoted returns the index of the first c in raw at or after start
+// that is not quoted, escaped or inside an expansion, or -1.
+func indexUnquoted(raw string, start int, c byte) int {
+	for i := start; i < len(raw); i++ {
+		if end := skipQuoted(raw, i); end > i {
+			i = end
+		} else if raw[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the last character of the quoted text,
+// escape, or "${...}" or "$(...)" expansion starting at raw[i], or i if
+// none starts there.
+func skipQuoted(raw string, i int) int {
+	switch raw[i] {
+	case '\\':
+		return min(i+1, len(raw)-1)
+	case '\'', '`':
+		if end := strings.IndexByte(raw[i+1:], raw[i]); end >= 0 {
+			return i + 1 + end
+		}
+		return len(raw) - 1
+	case '"':
+		for j := i + 1; j < len(raw); j++ {
+			switch raw[j] {
+			case '\\':
+				j++
+			case '"':
+				return j
+			case '$':
+				if end := skipQuoted(raw, j); end > j {
+					j = end
+				}
+			}
+		}
+		return len(raw) - 1
+	case '$':
+		if i+1 < len(raw) && (raw[i+1] == '{' || raw[i+1] == '(') {
+			close := byte('}')
+			if raw[i+1] == '(' {
+				close = ')'
+			}
+			if end := matchingClose(raw[i+1:], raw[i+1], close); end >= 0 {
+				return i + 1 + end
+			}
+		}
+	}
+	return i
+}
+
+// braceSequence expands the inside of a sequence expression, "x..y" or
+// "x..y..step", where x and y are both integers or both single characters.
+func braceSequence(body string) ([]string, bool) {
+	parts := strings.Split(body, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false
+	}
+	step := int64(1)
+	if len(parts) == 3 {
+		n, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		if n < 0 {
+			n = -n
+		}
+		if n != 0 {
+			step = n
+		}
+	}
+
+	from, errFrom := strconv.ParseInt(parts[0], 10, 64)
+	to, errTo := strconv.ParseInt(parts[1], 10, 64)
+	switch {
+	case errFrom == nil && errTo == nil:
+		width := 0
+		if zeroPadded(parts[0]) || zeroPadded(parts[1]) {
+			width = max(len(parts[0]), len(parts[1]))
+		}
+		var seq []string
+		for _, n := range sequence(from, to, step) {
+			seq = append(seq, fmt.Sprintf("%0*d", width, n))
+		}
+		return seq, true
+	case len(parts[0]) == 1 && len(parts[1]) == 1 && errFrom != nil && errTo != nil:
+		var seq []string
+		for _, c := range sequence(int64(parts[0][0]), int64(parts[1][0]), step) {
+			seq = append(seq, string(rune(c)))
+		}
+		return seq, true
+	}
+	return nil, false
+}
+
+// sequence counts from from to to by step, downwards if to is smaller.
+func sequence(from, to, step int64) []int64 {
+	var seq []int64
+	if from <= to {
+		for n := from; n <= to; n += step {
+			seq = append(seq, n)
+		}
+	} else {
+		for n := from; n >= to; n -= step {
+			seq = append(seq, n)
+		}
+	}
+	return seq
+}
+
+// zeroPadded reports whether a sequence bound has a leading zero, as in
+// "01" or "-05".
+func zeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
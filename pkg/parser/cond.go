@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+	"github.com/codecrafters-io/shell-starter-go/pkg/token"
+)
+
+// condUnaryOps and condBinaryOps are the operators of [[ ]], the same as
+// the test builtin's plus "=~".
+var (
+	condUnaryOps  = strings.Fields("-a -b -c -d -e -f -g -h -k -n -o -p -r -s -t -u -v -w -x -z -G -L -N -O -S")
+	condBinaryOps = strings.Fields("= == != =~ < > -eq -ne -lt -le -gt -ge -nt -ot -ef")
+)
+
+func isCondOp(ops []string, s string) bool {
+	for _, op := range ops {
+		if op == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCond parses "[[ expr ]]". Inside, && and || combine tests, ! negates
+// one and parentheses group them; < and > compare strings instead of
+// redirecting.
+func (p *Parser) parseCond() ast.Node {
+	p.nextToken() // consume '[['
+	expr := p.parseCondOr()
+	if expr == nil {
+		return nil
+	}
+	p.skipNewlines()
+	if !p.expect(token.DRBRACK) {
+		return nil
+	}
+	return &ast.CondNode{Expr: expr}
+}
+
+func (p *Parser) parseCondOr() ast.CondExpr {
+	left := p.parseCondAnd()
+	for left != nil && p.curToken.Type == token.OR {
+		p.nextToken()
+		right := p.parseCondAnd()
+		if right == nil {
+			return nil
+		}
+		left = &ast.CondBinary{Op: "||", Left: left, Right: right}
+	}
+	return left
+}
+
+func (p *Parser) parseCondAnd() ast.CondExpr {
+	left := p.parseCondNot()
+	for left != nil && p.curToken.Type == token.AND {
+		p.nextToken()
+		right := p.parseCondNot()
+		if right == nil {
+			return nil
+		}
+		left = &ast.CondBinary{Op: "&&", Left: left, Right: right}
+	}
+	return left
+}
+
+func (p *Parser) parseCondNot() ast.CondExpr {
+	p.skipNewlines()
+	if p.curToken.Type == token.BANG {
+		p.nextToken()
+		x := p.parseCondNot()
+		if x == nil {
+			return nil
+		}
+		return &ast.CondNot{X: x}
+	}
+	return p.parseCondPrimary()
+}
+
+func (p *Parser) parseCondPrimary() ast.CondExpr {
+	if p.curToken.Type == token.LPAREN {
+		p.nextToken()
+		x := p.parseCondOr()
+		if x == nil {
+			return nil
+		}
+		p.skipNewlines()
+		if !p.expect(token.RPAREN) {
+			return nil
+		}
+		return x
+	}
+	if !p.isCondWord() {
+		p.unexpectedToken()
+		return nil
+	}
+
+	word := p.curToken.Literal
+	if isCondOp(condUnaryOps, word) && p.peekIsCondWord() {
+		p.nextToken()
+		arg := ParseWord(p.curToken.Literal)
+		p.nextToken()
+		return &ast.CondUnary{Op: word, Arg: arg}
+	}
+	p.nextToken()
+
+	op := p.curToken.Literal
+	isOp := p.curToken.Type == token.REDIRECT && (op == "<" || op == ">") ||
+		p.curToken.Type == token.WORD && isCondOp(condBinaryOps, op)
+	if !isOp {
+		return &ast.CondWord{Word: ParseWord(word)}
+	}
+	p.nextToken()
+	if op == "=~" {
+		if regex := p.condRegex(); regex != "" {
+			return &ast.CondCompare{Op: op, Left: ParseWord(word), Right: ParseWord(regex)}
+		}
+	}
+	if !p.isCondWord() {
+		p.unexpectedToken()
+		return nil
+	}
+	right := ParseWord(p.curToken.Literal)
+	p.nextToken()
+	return &ast.CondCompare{Op: op, Left: ParseWord(word), Right: right}
+}
+
+// isCondWord reports whether the current token is an operand in [[ ]].
+// Reserved words other than "]]" are plain words there.
+func (p *Parser) isCondWord() bool {
+	return p.isWord() && p.curToken.Type != token.DRBRACK
+}
+
+func (p *Parser) peekIsCondWord() bool {
+	t := p.peekToken.Type
+	return (t == token.WORD || token.IsKeyword(t)) && t != token.DRBRACK
+}
+
+// condRegex reads the regular expression after "=~". Its parentheses and
+// '|' would otherwise be operators, so the tokens up to the end of the test
+// are joined back together.
+func (p *Parser) condRegex() string {
+	var b strings.Builder
+	depth := 0
+	for {
+		switch p.curToken.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth == 0 {
+				return b.String()
+			}
+			depth--
+		case token.AND, token.OR:
+			if depth == 0 {
+				return b.String()
+			}
+		case token.PIPE, token.WORD:
+		default:
+			if !p.isCondWord() {
+				return b.String()
+			}
+		}
+		b.WriteString(p.curToken.Literal)
+		p.nextToken()
+	}
+}
@@ -1,17 +1,29 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
 	"github.com/codecrafters-io/shell-starter-go/pkg/lexer"
 	"github.com/codecrafters-io/shell-starter-go/pkg/token"
+	"github.com/codecrafters-io/shell-starter-go/pkg/vars"
 )
 
 type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token
 	peekToken token.Token
+
+	// Alias expansion puts tokens back in front of the lexer's, and records
+	// where each of the current tokens came from
+	aliases  map[string]string
+	queue    []queuedToken
+	curInfo  aliasInfo
+	peekInfo aliasInfo
+
+	errors     []string
+	incomplete bool // input ended before the command was finished
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -22,22 +34,59 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.curToken, p.curInfo = p.peekToken, p.peekInfo
+	if len(p.queue) > 0 {
+		p.peekToken, p.peekInfo = p.queue[0].tok, p.queue[0].info
+		p.queue = p.queue[1:]
+		return
+	}
+	p.peekToken, p.peekInfo = p.l.NextToken(), aliasInfo{}
 }
 
 func (p *Parser) Parse() ast.Node {
-	return p.parseBlock()
+	block := p.parseBlock()
+	// parseBlock stops at reserved words like 'fi' that close a compound
+	// command; at the top level there is nothing for them to close.
+	for p.curToken.Type != token.EOF {
+		p.unexpectedToken()
+		p.nextToken()
+		block.Statements = append(block.Statements, p.parseBlock().Statements...)
+	}
+	return block
+}
+
+// Errors returns the syntax errors found while parsing.
+func (p *Parser) Errors() []string {
+	return p.errors
+}
+
+// Incomplete reports whether the input stopped in the middle of a command
+// (e.g. an unclosed quote), so more input could still make it valid.
+func (p *Parser) Incomplete() bool {
+	return p.incomplete
+}
+
+func (p *Parser) illegalError() {
+	p.incomplete = true
+	if len(p.errors) > 0 {
+		return
+	}
+	if p.curToken.Literal == "\\" {
+		p.errors = append(p.errors, "syntax error: unexpected end of file")
+	} else {
+		p.errors = append(p.errors, fmt.Sprintf("unexpected EOF while looking for matching `%s'", p.curToken.Literal))
+	}
 }
 
 //{stmt ; stmt ; stmt;} 
 func (p *Parser) parseBlock() *ast.BlockNode {
     block := &ast.BlockNode{Statements: []ast.Node{}}
 
-    for p.curToken.Type != token.EOF &&
-		p.curToken.Type != token.FI &&
-		p.curToken.Type != token.ELSE &&
-		p.curToken.Type != token.THEN {
+    for {
+		p.skipNewlines()
+		if p.atBlockEnd() {
+			break
+		}
 
 		stmt := p.parseLogical()
 		if stmt != nil {
@@ -46,11 +95,63 @@ func (p *Parser) parseBlock() *ast.BlockNode {
 
 		if p.curToken.Type == token.SEMICOLON || p.curToken.Type == token.NEWLINE {
 			p.nextToken()
+		} else if !p.atBlockEnd() {
+			// parseLogical stopped on something that cannot follow a command
+			p.unexpectedToken()
+			p.nextToken()
 		}
 	}
 	return block
 }
 
+// atBlockEnd reports whether the current token closes the enclosing block.
+func (p *Parser) atBlockEnd() bool {
+	switch p.curToken.Type {
+	case token.EOF, token.THEN, token.ELIF, token.ELSE, token.FI, token.DO, token.DONE,
+		token.ESAC, token.DSEMI, token.SEMI_AND, token.DSEMI_AND, token.RBRACE, token.RPAREN:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) skipNewlines() {
+	for p.curToken.Type == token.NEWLINE {
+		p.nextToken()
+	}
+}
+
+// unexpectedToken records a syntax error for the current token. Running into
+// EOF means the command is merely unfinished.
+func (p *Parser) unexpectedToken() {
+	if len(p.errors) > 0 {
+		return // like bash, only the first syntax error is worth reporting
+	}
+	if p.curToken.Type == token.EOF {
+		p.errors = append(p.errors, "syntax error: unexpected end of file")
+		p.incomplete = true
+		return
+	}
+	literal := p.curToken.Literal
+	switch p.curToken.Type {
+	case token.NEWLINE:
+		literal = "newline"
+	case token.ARITH:
+		literal = "(("
+	}
+	p.errors = append(p.errors, fmt.Sprintf("syntax error near unexpected token `%s'", literal))
+}
+
+// expect consumes the current token if it has type t, otherwise it records a
+// syntax error and returns false.
+func (p *Parser) expect(t token.TokenType) bool {
+	if p.curToken.Type != t {
+		p.unexpectedToken()
+		return false
+	}
+	p.nextToken()
+	return true
+}
+
 func (p *Parser) parseLogical() ast.Node {
 	// Parse && / || chain first (higher precedence than &)
 	left := p.parseAndOr()
@@ -61,11 +162,9 @@ func (p *Parser) parseLogical() ast.Node {
 		p.nextToken() // consume '&'
 		// There may be a follow-on command after '&' on the same line (e.g. "sleep 10 & echo hello")
 		var right ast.Node
-		if p.curToken.Type != token.EOF &&
-			p.curToken.Type != token.SEMICOLON &&
-			p.curToken.Type != token.FI &&
-			p.curToken.Type != token.ELSE &&
-			p.curToken.Type != token.THEN {
+		if p.curToken.Type != token.SEMICOLON &&
+			p.curToken.Type != token.NEWLINE &&
+			!p.atBlockEnd() {
 			right = p.parseLogical()
 		}
 		return &ast.BinaryNode{Left: left, Operator: "&", Right: right}
@@ -80,6 +179,7 @@ func (p *Parser) parseAndOr() ast.Node {
 	for p.curToken.Type == token.AND || p.curToken.Type == token.OR {
 		operator := p.curToken.Literal
 		p.nextToken()
+		p.skipNewlines()
 		right := p.parsePipeline()
 		left = &ast.BinaryNode{
 			Left:     left,
@@ -90,89 +190,513 @@ func (p *Parser) parseAndOr() ast.Node {
 	return left
 }
 
-// parsePipeline handles "cmd | cmd | cmd"
+// parsePipeline handles "cmd | cmd | cmd", optionally preceded by "time
+// [-p]" and "!"
 func (p *Parser) parsePipeline() ast.Node {
-	left := p.parseCommand()
+	var time, posix, negate bool
+	if p.curToken.Type == token.TIME {
+		time = true
+		p.nextToken()
+		if p.curToken.Type == token.WORD && p.curToken.Literal == "-p" {
+			posix = true
+			p.nextToken()
+		}
+	}
+	for p.curToken.Type == token.BANG {
+		negate = !negate
+		p.nextToken()
+	}
+
+	pipe := &ast.PipeNode{Negate: negate, Time: time, TimePOSIX: posix}
+	// "time" or "!" on its own applies to an empty command
+	if (!time && !negate) || !p.atPipelineEnd() {
+		pipe.Stages = append(pipe.Stages, p.parseCommand())
+	}
 
 	for p.curToken.Type == token.PIPE {
 		p.nextToken() // consume '|'
-		right := p.parseCommand()
-		left = &ast.PipeNode{Left: left, Right: right}
+		p.skipNewlines()
+		pipe.Stages = append(pipe.Stages, p.parseCommand())
 	}
-	return left
+
+	if len(pipe.Stages) == 1 && !time && !negate {
+		return pipe.Stages[0]
+	}
+	return pipe
+}
+
+// atPipelineEnd reports whether the current token ends a pipeline.
+func (p *Parser) atPipelineEnd() bool {
+	switch p.curToken.Type {
+	case token.SEMICOLON, token.NEWLINE, token.AND, token.OR, token.BACKGROUND:
+		return true
+	}
+	return p.atBlockEnd()
 }
 
 func (p *Parser) parseCommand() ast.Node {
-    if p.curToken.Type == token.IF {
-        return p.parseIf()
+    p.expandAlias()
+    var compound ast.Node
+    switch p.curToken.Type {
+    case token.IF:
+        compound = p.parseIf()
+    case token.WHILE, token.UNTIL:
+        compound = p.parseWhile()
+    case token.FOR:
+        compound = p.parseFor()
+    case token.CASE:
+        compound = p.parseCase()
+    case token.LBRACE:
+        compound = p.parseBraceGroup()
+    case token.LPAREN:
+        compound = p.parseSubshell()
+    case token.DLBRACK:
+        compound = p.parseCond()
+    case token.ARITH:
+        compound = &ast.ArithNode{Expr: p.curToken.Literal}
+        p.nextToken()
+    case token.FUNCTION:
+        return p.parseFunction()
+    default:
+        if p.curToken.Type == token.WORD && p.peekToken.Type == token.LPAREN && !isAssignment(p.curToken.Literal) {
+            return p.parseFunction()
+        }
+        return p.parseSimpleCommand()
+    }
+    if compound == nil {
+        return nil
     }
-    cmd := &ast.CommandNode{Args: []string{}} 
+    return p.parseTrailingRedirects(compound)
+}
+
+// parseTrailingRedirects applies the redirections after a compound command
+// to all of it, e.g. "while read line; do ...; done < file".
+func (p *Parser) parseTrailingRedirects(node ast.Node) ast.Node {
+	for p.curToken.Type == token.REDIRECT {
+		var ok bool
+		if node, ok = p.parseRedirect(node); !ok {
+			break
+		}
+	}
+	return node
+}
+
+func (p *Parser) parseSimpleCommand() ast.Node {
+    cmd := &ast.CommandNode{Args: []*ast.Word{}} 
     var result ast.Node = cmd
 
-    for p.curToken.Type != token.EOF && 
-        p.curToken.Type != token.PIPE && 
-        p.curToken.Type != token.SEMICOLON &&
-        p.curToken.Type != token.THEN &&  
-        p.curToken.Type != token.ELSE &&  
-        p.curToken.Type != token.FI &&
-        p.curToken.Type != token.AND &&
-        p.curToken.Type != token.OR &&
-        p.curToken.Type != token.BACKGROUND {    
-        if p.curToken.Type == token.REDIRECT {
-            op := p.curToken.Literal
+    // Any operator (|, ;, &&, ), ...) ends the command
+    for p.isWord() || 
+        p.curToken.Type == token.REDIRECT || 
+        p.curToken.Type == token.ILLEGAL {    
+        // Reserved words are only special in command position ("echo fi" is fine)
+        if token.IsKeyword(p.curToken.Type) && len(cmd.Args) == 0 {
+            break
+        }
+        if p.curToken.Type == token.ILLEGAL {
+            p.illegalError()
             p.nextToken()
-
-            if p.curToken.Type != token.WORD {
+            continue
+        }
+        if p.curToken.Type == token.REDIRECT {
+            var ok bool
+            if result, ok = p.parseRedirect(result); !ok {
                 return result
             }
-            filename := p.curToken.Literal
-            p.nextToken()
-
-            fd := 1
-            if strings.HasPrefix(op, "2") {
-                fd = 2
+        } else if len(cmd.Args) == 0 && isAssignment(p.curToken.Literal) {
+            a, ok := p.parseAssignmentWord()
+            if !ok {
+                return nil
             }
-
-            result = &ast.RedirectNode{
-                Stmt:     result,
-                Location: filename,
-                Type:     op,
-                Fd:       fd,
+            cmd.Assigns = append(cmd.Assigns, a)
+        } else if isDeclaration(cmd.Args) && isAssignment(p.curToken.Literal) {
+            a, ok := p.parseAssignmentWord()
+            if !ok {
+                return nil
             }
+            cmd.Args = append(cmd.Args, &ast.Word{Raw: a.Raw, Parts: ParseWord(a.Raw).Parts, Assign: a})
+        } else if (len(cmd.Args) == 0 || p.curInfo.check) && p.expandAlias() {
+            // The command name after assignments, or the word after an
+            // alias ending in a blank, may be an alias too
+            continue
         } else {
-            cmd.Args = append(cmd.Args, p.curToken.Literal)
+            cmd.Args = append(cmd.Args, ParseWord(p.curToken.Literal))
             p.nextToken()
         }
     }
+
+    if len(cmd.Args) == 0 && len(cmd.Assigns) == 0 && result == ast.Node(cmd) {
+        // Nothing before an operator such as "| wc" or "true && then"
+        p.unexpectedToken()
+    }
     return result
 }
 
+// parseRedirect wraps stmt in a RedirectNode for the redirection operator at
+// the current token. It returns false if the operator has no target.
+func (p *Parser) parseRedirect(stmt ast.Node) (ast.Node, bool) {
+    op := p.curToken.Literal
+    p.nextToken()
+
+    if p.curToken.Type == token.ILLEGAL {
+        p.illegalError()
+        p.nextToken()
+        return stmt, false
+    }
+    if !p.isWord() {
+        p.unexpectedToken()
+        return stmt, false
+    }
+    filename := p.curToken.Literal
+    p.nextToken()
+
+    fd := 1
+    if strings.HasPrefix(op, "2") {
+        fd = 2
+    }
+
+    return &ast.RedirectNode{
+        Stmt:     stmt,
+        Location: ParseWord(filename),
+        Type:     op,
+        Fd:       fd,
+    }, true
+}
+
+// isAssignment reports whether a raw word has the form NAME=value.
+func isAssignment(word string) bool {
+	lhs, _, ok := strings.Cut(word, "=")
+	lhs = strings.TrimSuffix(lhs, "+")
+	if i := strings.IndexByte(lhs, '['); i > 0 && strings.HasSuffix(lhs, "]") {
+		lhs = lhs[:i]
+	}
+	return ok && vars.IsName(lhs)
+}
+
+// isDeclaration reports whether args start with a builtin that takes
+// assignments as arguments, so "local arr=(a b)" can assign an array.
+func isDeclaration(args []*ast.Word) bool {
+	if len(args) == 0 {
+		return false
+	}
+	name, _ := args[0].Lit()
+	switch name {
+	case "declare", "typeset", "local", "export", "readonly":
+		return true
+	}
+	return false
+}
+
+// parseAssignmentWord consumes an assignment word. An array assignment
+// "name=(a b c)" also takes the words up to the closing parenthesis, which
+// may span lines.
+func (p *Parser) parseAssignmentWord() (*ast.Assignment, bool) {
+	word := p.curToken.Literal
+	p.nextToken()
+	if !strings.HasSuffix(word, "=") || p.curToken.Type != token.LPAREN {
+		return parseAssignment(word), true
+	}
+	p.nextToken() // consume '('
+
+	a := parseAssignment(word)
+	a.IsArray = true
+	var raw []string
+	for {
+		p.skipNewlines()
+		if !p.isWord() {
+			break
+		}
+		raw = append(raw, p.curToken.Literal)
+		a.Array = append(a.Array, parseArrayElem(p.curToken.Literal))
+		p.nextToken()
+	}
+	if p.curToken.Type == token.ILLEGAL {
+		p.illegalError()
+		return nil, false
+	}
+	if !p.expect(token.RPAREN) {
+		return nil, false
+	}
+	a.Raw = word + "(" + strings.Join(raw, " ") + ")"
+	return a, true
+}
+
+// isWord reports whether the current token can be used as a plain word.
+// Reserved words count as words outside of command position.
+func (p *Parser) isWord() bool {
+	return p.curToken.Type == token.WORD || token.IsKeyword(p.curToken.Type)
+}
+
+// parseIf parses "if list; then list; [elif list; then list;]... [else list;] fi".
+// Each elif becomes a nested IfNode in the Else branch of the one before it.
 func (p *Parser) parseIf() ast.Node {
-    p.nextToken() // consume 'if'
+    p.nextToken() // consume 'if' or 'elif'
     condition := p.parseBlock()
+    if len(condition.Statements) == 0 {
+        p.unexpectedToken()
+        return nil
+    }
 
-    if p.curToken.Type != token.THEN {
+    if !p.expect(token.THEN) {
         return nil
     }
-    p.nextToken() // consume 'then'
     consequence := p.parseBlock()
+    if len(consequence.Statements) == 0 {
+        p.unexpectedToken()
+        return nil
+    }
 
-    var alternative ast.Node = nil
+    node := &ast.IfNode{
+        Condition: condition, 
+        Then:      consequence, 
+    }
 
-    // Check if we hit an 'ELSE' before 'FI'
-    if p.curToken.Type == token.ELSE {
+    switch p.curToken.Type {
+    case token.ELIF:
+        // The nested if consumes the shared 'fi'
+        elif := p.parseIf()
+        if elif == nil {
+            return nil
+        }
+        node.Else = elif
+        return node
+    case token.ELSE:
         p.nextToken() // consume 'else'
-        alternative = p.parseBlock()
+        alternative := p.parseBlock()
+        if len(alternative.Statements) == 0 {
+            p.unexpectedToken()
+            return nil
+        }
+        node.Else = alternative
     }
 
-    if p.curToken.Type != token.FI {
+    if !p.expect(token.FI) {
         return nil
     }
-    p.nextToken() // consume 'fi'
+    return node
+}
 
-    return &ast.IfNode{
-        Condition: condition, 
-        Then:      consequence, 
-        Else:      alternative,
-    }
-}
\ No newline at end of file
+// parseWhile parses "while list; do list; done" and the until form.
+func (p *Parser) parseWhile() ast.Node {
+	until := p.curToken.Type == token.UNTIL
+	p.nextToken() // consume 'while' or 'until'
+	condition := p.parseBlock()
+	if len(condition.Statements) == 0 {
+		p.unexpectedToken()
+		return nil
+	}
+
+	body := p.parseDoGroup()
+	if body == nil {
+		return nil
+	}
+	return &ast.WhileNode{Condition: condition, Body: body, Until: until}
+}
+
+// parseFor parses "for name [in words]; do list; done" and the C-style
+// "for ((init; cond; step)); do list; done".
+func (p *Parser) parseFor() ast.Node {
+	p.nextToken() // consume 'for'
+	if p.curToken.Type == token.ARITH {
+		return p.parseArithFor()
+	}
+
+	if !p.isWord() {
+		p.unexpectedToken()
+		return nil
+	}
+	name := p.curToken.Literal
+	if !vars.IsName(name) {
+		p.errors = append(p.errors, fmt.Sprintf("`%s': not a valid identifier", name))
+		return nil
+	}
+	p.nextToken()
+
+	node := &ast.ForNode{Name: name}
+	p.skipNewlines()
+	if p.curToken.Type == token.IN {
+		p.nextToken() // consume 'in'
+		node.Words = []*ast.Word{}
+		for p.isWord() {
+			node.Words = append(node.Words, ParseWord(p.curToken.Literal))
+			p.nextToken()
+		}
+		if p.curToken.Type != token.SEMICOLON && p.curToken.Type != token.NEWLINE {
+			p.unexpectedToken()
+			return nil
+		}
+		p.nextToken()
+	} else if p.curToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+	p.skipNewlines()
+
+	if node.Body = p.parseDoGroup(); node.Body == nil {
+		return nil
+	}
+	return node
+}
+
+func (p *Parser) parseArithFor() ast.Node {
+	parts := strings.Split(p.curToken.Literal, ";")
+	if len(parts) != 3 {
+		p.errors = append(p.errors, fmt.Sprintf("syntax error: arithmetic expression required: `((%s))'", p.curToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	if p.curToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+	p.skipNewlines()
+
+	body := p.parseDoGroup()
+	if body == nil {
+		return nil
+	}
+	return &ast.ArithForNode{
+		Init: strings.TrimSpace(parts[0]),
+		Cond: strings.TrimSpace(parts[1]),
+		Step: strings.TrimSpace(parts[2]),
+		Body: body,
+	}
+}
+
+// parseDoGroup parses the "do list; done" body of a loop.
+func (p *Parser) parseDoGroup() ast.Node {
+	if !p.expect(token.DO) {
+		return nil
+	}
+	body := p.parseBlock()
+	if len(body.Statements) == 0 {
+		p.unexpectedToken()
+		return nil
+	}
+	if !p.expect(token.DONE) {
+		return nil
+	}
+	return body
+}
+
+// parseCase parses "case word in [(]pattern[|pattern]...) list ;; ... esac".
+// An item may also end with ";&" or ";;&", and the last one may omit ";;".
+func (p *Parser) parseCase() ast.Node {
+	p.nextToken() // consume 'case'
+	if !p.isWord() {
+		p.unexpectedToken()
+		return nil
+	}
+	node := &ast.CaseNode{Word: ParseWord(p.curToken.Literal)}
+	p.nextToken()
+
+	p.skipNewlines()
+	if !p.expect(token.IN) {
+		return nil
+	}
+	p.skipNewlines()
+
+	for p.curToken.Type != token.ESAC {
+		item := ast.CaseItem{}
+		if p.curToken.Type == token.LPAREN {
+			p.nextToken()
+		}
+		for {
+			if !p.isWord() {
+				p.unexpectedToken()
+				return nil
+			}
+			item.Patterns = append(item.Patterns, ParseWord(p.curToken.Literal))
+			p.nextToken()
+			if p.curToken.Type != token.PIPE {
+				break
+			}
+			p.nextToken() // consume '|'
+		}
+		if !p.expect(token.RPAREN) {
+			return nil
+		}
+
+		item.Body = p.parseBlock()
+		switch p.curToken.Type {
+		case token.DSEMI, token.SEMI_AND, token.DSEMI_AND:
+			item.Terminator = p.curToken.Literal
+			p.nextToken()
+		case token.ESAC:
+			item.Terminator = ";;"
+		default:
+			p.unexpectedToken()
+			return nil
+		}
+		node.Items = append(node.Items, item)
+		p.skipNewlines()
+	}
+	p.nextToken() // consume 'esac'
+	return node
+}
+
+// parseFunction parses a function definition, "name() body" or
+// "function name [()] body", where body is a compound command.
+func (p *Parser) parseFunction() ast.Node {
+	if p.curToken.Type == token.FUNCTION {
+		p.nextToken()
+		if !p.isWord() {
+			p.unexpectedToken()
+			return nil
+		}
+	}
+	name := p.curToken.Literal
+	if strings.ContainsAny(name, "'\"\\$`=") {
+		p.errors = append(p.errors, fmt.Sprintf("`%s': not a valid identifier", name))
+		return nil
+	}
+	p.nextToken()
+
+	if p.curToken.Type == token.LPAREN {
+		p.nextToken()
+		if !p.expect(token.RPAREN) {
+			return nil
+		}
+	}
+	p.skipNewlines()
+
+	var body ast.Node
+	switch p.curToken.Type {
+	case token.LBRACE, token.LPAREN, token.IF, token.WHILE, token.UNTIL, token.FOR, token.CASE,
+		token.DLBRACK, token.ARITH:
+		body = p.parseCommand()
+	default:
+		p.unexpectedToken()
+		return nil
+	}
+	if body == nil {
+		return nil
+	}
+	return &ast.FunctionNode{Name: name, Body: body}
+}
+
+// parseBraceGroup parses "{ list; }", which runs in the current shell.
+func (p *Parser) parseBraceGroup() ast.Node {
+	p.nextToken() // consume '{'
+	body := p.parseBlock()
+	if len(body.Statements) == 0 {
+		p.unexpectedToken()
+		return nil
+	}
+	if !p.expect(token.RBRACE) {
+		return nil
+	}
+	return body
+}
+
+// parseSubshell parses "( list )", which runs in a copy of the shell.
+func (p *Parser) parseSubshell() ast.Node {
+	p.nextToken() // consume '('
+	body := p.parseBlock()
+	if len(body.Statements) == 0 {
+		p.unexpectedToken()
+		return nil
+	}
+	if !p.expect(token.RPAREN) {
+		return nil
+	}
+	return &ast.SubshellNode{Body: body}
+}
@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/ast"
+)
+
+// ParseWord splits a raw word, as the lexer returns it with its quotes and
+// backslashes still in place, into the parts that expand differently.
+func ParseWord(raw string) *ast.Word {
+	wp := &wordParser{src: raw}
+	return &ast.Word{Raw: raw, Parts: wp.parts(false)}
+}
+
+// ParseArith parses the text of an arithmetic expression like ParseWord,
+// except that '~' is the bitwise not operator rather than a tilde prefix.
+func ParseArith(raw string) *ast.Word {
+	wp := &wordParser{src: raw, arith: true}
+	return &ast.Word{Raw: raw, Parts: wp.parts(false)}
+}
+
+// parseAssignValue parses the value of an assignment, where a tilde prefix
+// may also follow a ':', as in "PATH=~/bin:~/go/bin".
+func parseAssignValue(raw string) *ast.Word {
+	wp := &wordParser{src: raw, assign: true}
+	return &ast.Word{Raw: raw, Parts: wp.parts(false)}
+}
+
+// wordParser scans the raw text of a word.
+type wordParser struct {
+	src    string
+	pos    int
+	assign bool // the word is an assignment value
+	arith  bool // the word is an arithmetic expression, with no tilde prefixes
+}
+
+// parts parses parts up to the end of the word, or up to the closing quote
+// when parsing the inside of double quotes.
+func (wp *wordParser) parts(quoted bool) []ast.WordPart {
+	var parts []ast.WordPart
+	var lit strings.Builder
+	add := func(part ast.WordPart) {
+		if lit.Len() > 0 {
+			parts = append(parts, &ast.Literal{Text: lit.String()})
+			lit.Reset()
+		}
+		if part != nil {
+			parts = append(parts, part)
+		}
+	}
+
+	for wp.pos < len(wp.src) {
+		c := wp.src[wp.pos]
+		switch {
+		case quoted && c == '"':
+			wp.pos++
+			add(nil)
+			return parts
+		case !quoted && c == '\'':
+			end := strings.IndexByte(wp.src[wp.pos+1:], '\'')
+			if end < 0 {
+				end = len(wp.src) - wp.pos - 1
+			}
+			add(&ast.SingleQuoted{Text: wp.src[wp.pos+1 : wp.pos+1+end]})
+			wp.pos += end + 2
+		case !quoted && c == '"':
+			wp.pos++
+			add(&ast.DoubleQuoted{Parts: wp.parts(true)})
+		case c == '\\':
+			// Inside double quotes a backslash only escapes $, `, " and \
+			if wp.pos+1 == len(wp.src) || (quoted && strings.IndexByte("$`\"\\", wp.src[wp.pos+1]) < 0) {
+				lit.WriteByte(c)
+				wp.pos++
+				continue
+			}
+			add(&ast.SingleQuoted{Text: wp.src[wp.pos+1 : wp.pos+2]})
+			wp.pos += 2
+		case c == '$':
+			if part := wp.dollar(); part != nil {
+				add(part)
+			} else {
+				lit.WriteByte(c)
+				wp.pos++
+			}
+		case c == '`':
+			add(wp.backquote())
+		case !quoted && (c == '<' || c == '>') && wp.pos+1 < len(wp.src) && wp.src[wp.pos+1] == '(':
+			end := matchingClose(wp.src[wp.pos+1:], '(', ')')
+			if end < 0 {
+				lit.WriteByte(c)
+				wp.pos++
+				continue
+			}
+			add(&ast.ProcSubst{Source: wp.src[wp.pos+2 : wp.pos+1+end], Output: c == '>'})
+			wp.pos += 1 + end + 1
+		case !quoted && !wp.arith && c == '~' && (wp.pos == 0 || (wp.assign && wp.src[wp.pos-1] == ':')):
+			if part := wp.tilde(); part != nil {
+				add(part)
+			} else {
+				lit.WriteByte(c)
+				wp.pos++
+			}
+		default:
+			lit.WriteByte(c)
+			wp.pos++
+		}
+	}
+	add(nil)
+	return parts
+}
+
+// dollar parses the expansion starting with the '$' at the current
+// position. It returns nil if the '$' does not start one.
+func (wp *wordParser) dollar() ast.WordPart {
+	rest := wp.src[wp.pos+1:]
+	if rest == "" {
+		return nil
+	}
+	switch c := rest[0]; {
+	case strings.HasPrefix(rest, "(("):
+		if end := arithEnd(rest[2:]); end >= 0 {
+			wp.pos += 1 + 2 + end + 2
+			return &ast.ArithExp{Expr: rest[2 : 2+end]}
+		}
+		fallthrough
+	case c == '(':
+		end := matchingClose(rest, '(', ')')
+		if end < 0 {
+			return nil
+		}
+		wp.pos += 1 + end + 1
+		return &ast.CmdSubst{Source: rest[1:end]}
+	case c == '{':
+		end := matchingClose(rest, '{', '}')
+		if end < 0 {
+			return nil
+		}
+		wp.pos += 1 + end + 1
+		return &ast.ParamExp{Body: rest[1:end], Braced: true}
+	case isNameStart(c):
+		n := 1
+		for n < len(rest) && isNameChar(rest[n]) {
+			n++
+		}
+		wp.pos += 1 + n
+		return &ast.ParamExp{Body: rest[:n]}
+	case strings.IndexByte("?$#@*!-0123456789", c) >= 0:
+		wp.pos += 2
+		return &ast.ParamExp{Body: rest[:1]}
+	}
+	return nil
+}
+
+// backquote parses the old-style command substitution `...`. Inside it a
+// backslash only escapes $, ` and \.
+func (wp *wordParser) backquote() ast.WordPart {
+	var src strings.Builder
+	i := wp.pos + 1
+	for ; i < len(wp.src) && wp.src[i] != '`'; i++ {
+		if wp.src[i] == '\\' && i+1 < len(wp.src) && strings.IndexByte("$`\\", wp.src[i+1]) >= 0 {
+			i++
+		}
+		src.WriteByte(wp.src[i])
+	}
+	wp.pos = i + 1
+	return &ast.CmdSubst{Source: src.String(), Backquote: true}
+}
+
+// tilde parses a tilde prefix such as "~" or "~user", which runs up to the
+// first '/', or in an assignment value up to the first ':'. It returns nil
+// if the prefix contains quotes or expansions.
+func (wp *wordParser) tilde() ast.WordPart {
+	stop := "/"
+	if wp.assign {
+		stop = "/:"
+	}
+	end := strings.IndexAny(wp.src[wp.pos:], stop)
+	if end < 0 {
+		end = len(wp.src) - wp.pos
+	}
+	user := wp.src[wp.pos+1 : wp.pos+end]
+	if strings.ContainsAny(user, "'\"\\$`") {
+		return nil
+	}
+	wp.pos += end
+	return &ast.Tilde{User: user}
+}
+
+// arithEnd returns the index of the "))" that ends an arithmetic expansion
+// in s, which starts just after "$((", or -1 if there is none.
+func arithEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				if i+1 < len(s) && s[i+1] == ')' {
+					return i
+				}
+				return -1
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// matchingClose returns the index of the close character that matches the
+// open character at s[0], skipping nested pairs and quoted text, or -1 if
+// there is none.
+func matchingClose(s string, open, close byte) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == open:
+			depth++
+		case c == close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseAssignment splits an assignment word into its parts. The value of an
+// array assignment is parsed separately from the words between the
+// parentheses.
+func parseAssignment(word string) *ast.Assignment {
+	lhs, value, _ := strings.Cut(word, "=")
+	a := &ast.Assignment{Raw: word, Value: parseAssignValue(value)}
+	if strings.HasSuffix(lhs, "+") {
+		a.Append = true
+		lhs = lhs[:len(lhs)-1]
+	}
+	if name, sub, ok := strings.Cut(lhs, "["); ok {
+		lhs = name
+		a.Index = ParseWord(strings.TrimSuffix(sub, "]"))
+	}
+	a.Name = lhs
+	return a
+}
+
+// parseArrayElem parses one word inside "name=(...)".
+func parseArrayElem(word string) ast.ArrayElem {
+	if key, value, ok := strings.Cut(word, "]="); ok && strings.HasPrefix(word, "[") {
+		return ast.ArrayElem{Key: ParseWord(key[1:]), Value: ParseWord(value)}
+	}
+	return ast.ArrayElem{Value: ParseWord(word)}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
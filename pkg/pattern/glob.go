@@ -0,0 +1,117 @@
+package pattern
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HasMeta reports whether pattern contains a glob character that is not
+// escaped with a backslash.
+func HasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// Glob returns the sorted paths that match pattern, with relative patterns
+// resolved against dir. Each component of the path is matched separately,
+// and a file whose name starts with '.' only matches a component that
+// starts with '.' too.
+func Glob(pattern, dir string) []string {
+	comps := strings.Split(pattern, "/")
+	paths := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		paths = []string{"/"}
+		comps = comps[1:]
+	}
+
+	for i, comp := range comps {
+		var next []string
+		switch {
+		case comp == "" && i == len(comps)-1:
+			// A trailing slash only matches directories
+			for _, p := range paths {
+				if info, err := os.Stat(resolve(dir, p)); err == nil && info.IsDir() {
+					next = append(next, p+"/")
+				}
+			}
+		case comp == "":
+			next = paths
+		case !HasMeta(comp):
+			for _, p := range paths {
+				next = append(next, join(p, unescape(comp)))
+			}
+		default:
+			for _, p := range paths {
+				entries, err := os.ReadDir(resolve(dir, p))
+				if err != nil {
+					continue
+				}
+				for _, entry := range entries {
+					name := entry.Name()
+					if name[0] == '.' && comp[0] != '.' {
+						continue
+					}
+					if Match(comp, name) {
+						next = append(next, join(p, name))
+					}
+				}
+			}
+		}
+		paths = next
+	}
+
+	// Components without glob characters were not checked against the
+	// filesystem yet
+	var matches []string
+	for _, p := range paths {
+		if _, err := os.Lstat(resolve(dir, p)); err == nil {
+			matches = append(matches, p)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// unescape removes the backslashes from a pattern without glob characters.
+func unescape(pattern string) string {
+	if !strings.Contains(pattern, `\`) {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+func resolve(dir, path string) string {
+	if path == "" {
+		return dir
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,223 @@
+package pattern
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match reports whether s matches the shell pattern as a whole. In a pattern
+// '*' matches any string, '?' any single character and "[...]" one character
+// from a set; a backslash makes the next character literal.
+func Match(pattern, s string) bool {
+	// Classic backtracking matcher: on a mismatch, let the most recent '*'
+	// swallow one more character and retry from there.
+	px, sx := 0, 0
+	starPx, starSx := -1, -1
+
+	for sx < len(s) {
+		if px < len(pattern) {
+			switch pattern[px] {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+			case '?':
+				_, n := utf8.DecodeRuneInString(s[sx:])
+				px++
+				sx += n
+				continue
+			case '[':
+				r, n := utf8.DecodeRuneInString(s[sx:])
+				if ok, width, valid := matchBracket(pattern[px:], r); valid {
+					if ok {
+						px += width
+						sx += n
+						continue
+					}
+					break
+				}
+				// An unclosed '[' is an ordinary character
+				if s[sx] == '[' {
+					px++
+					sx++
+					continue
+				}
+			default:
+				pc, pn := literalAt(pattern, px)
+				sc, sn := utf8.DecodeRuneInString(s[sx:])
+				if pc == sc {
+					px += pn
+					sx += sn
+					continue
+				}
+			}
+		}
+		if starPx < 0 {
+			return false
+		}
+		_, n := utf8.DecodeRuneInString(s[starSx:])
+		starSx += n
+		px, sx = starPx+1, starSx
+	}
+
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// literalAt decodes the character at pattern[i], honouring a backslash
+// escape, and returns it with the number of bytes it used.
+func literalAt(pattern string, i int) (rune, int) {
+	if pattern[i] == '\\' && i+1 < len(pattern) {
+		r, n := utf8.DecodeRuneInString(pattern[i+1:])
+		return r, n + 1
+	}
+	return utf8.DecodeRuneInString(pattern[i:])
+}
+
+// matchBracket matches r against the bracket expression at the start of
+// pattern. It returns whether r is in the set, the width of the expression,
+// and false for valid if the bracket is never closed.
+func matchBracket(pattern string, r rune) (ok bool, width int, valid bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for i < len(pattern) {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+
+		if pattern[i] == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				end += i + 2
+				if matchClass(pattern[i+2:end], r) {
+					matched = true
+				}
+				i = end + 2
+				continue
+			}
+		}
+
+		lo, n := literalAt(pattern, i)
+		i += n
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, n = literalAt(pattern, i+1)
+			i += 1 + n
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
+
+func matchClass(class string, r rune) bool {
+	switch class {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return r >= '0' && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+	}
+	return false
+}
+
+// Prefix returns the length of the shortest prefix of s that matches
+// pattern, or of the longest one if longest is set. It returns -1 if no
+// prefix matches.
+func Prefix(pattern, s string, longest bool) int {
+	if longest {
+		for i := len(s); i >= 0; i-- {
+			if utf8.RuneStart(at(s, i)) && Match(pattern, s[:i]) {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := 0; i <= len(s); i++ {
+		if utf8.RuneStart(at(s, i)) && Match(pattern, s[:i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Suffix returns where the shortest suffix of s that matches pattern
+// starts, or the longest one if longest is set. It returns -1 if no suffix
+// matches.
+func Suffix(pattern, s string, longest bool) int {
+	if longest {
+		for i := 0; i <= len(s); i++ {
+			if utf8.RuneStart(at(s, i)) && Match(pattern, s[i:]) {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := len(s); i >= 0; i-- {
+		if utf8.RuneStart(at(s, i)) && Match(pattern, s[i:]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Replace replaces the first non-empty match of pattern in s with repl, or
+// every match if all is set. At each position the longest match wins.
+func Replace(s, pattern, repl string, all bool) string {
+	var b strings.Builder
+	i := 0
+	for i < len(s) {
+		if n := Prefix(pattern, s[i:], true); n > 0 {
+			b.WriteString(repl)
+			i += n
+			if !all {
+				break
+			}
+			continue
+		}
+		_, n := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+n])
+		i += n
+	}
+	b.WriteString(s[i:])
+	return b.String()
+}
+
+// at returns s[i], or a character that starts a rune when i is the end of
+// s, so that every index up to len(s) can be tested as a boundary.
+func at(s string, i int) byte {
+	if i == len(s) {
+		return 0
+	}
+	return s[i]
+}
@@ -0,0 +1,73 @@
+package pattern
+
+import "testing"
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		longest    bool
+		want       int
+	}{
+		{"*/", "/usr/local/lib", false, 1},
+		{"*/", "/usr/local/lib", true, 11},
+		{"u*", "usr", false, 1},
+		{"u*", "usr", true, 3},
+		{"*", "abc", false, 0},
+		{"*", "abc", true, 3},
+		{"x*", "abc", false, -1},
+		{"[a-c]?", "abc", true, 2},
+		{"é*", "été", false, 2},
+		{"?", "été", true, 2},
+		{"", "abc", true, 0},
+	}
+	for _, tt := range tests {
+		if got := Prefix(tt.pattern, tt.s, tt.longest); got != tt.want {
+			t.Errorf("Prefix(%q, %q, %v) = %d, want %d", tt.pattern, tt.s, tt.longest, got, tt.want)
+		}
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		longest    bool
+		want       int
+	}{
+		{".*", "file.tar.gz", false, 8},
+		{".*", "file.tar.gz", true, 4},
+		{"*", "abc", false, 3},
+		{"*", "abc", true, 0},
+		{"x*", "abc", true, -1},
+		{"/*", "/a/b", false, 2},
+		{"?", "été", false, 3},
+		{"", "abc", false, 3},
+	}
+	for _, tt := range tests {
+		if got := Suffix(tt.pattern, tt.s, tt.longest); got != tt.want {
+			t.Errorf("Suffix(%q, %q, %v) = %d, want %d", tt.pattern, tt.s, tt.longest, got, tt.want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		s, pattern, repl string
+		all              bool
+		want             string
+	}{
+		{"hello world", "o", "0", false, "hell0 world"},
+		{"hello world", "o", "0", true, "hell0 w0rld"},
+		{"hello world", "o", "", true, "hell wrld"},
+		{"hello world", "[lo]*", "X", false, "heX"},
+		{"hello world", "l?", "L", true, "heLo worL"},
+		{"aaa", "a*", "b", true, "b"},
+		{"abc", "x", "y", true, "abc"},
+		{"abc", "*", "y", true, "y"},
+		{"été", "é", "e", true, "ete"},
+	}
+	for _, tt := range tests {
+		if got := Replace(tt.s, tt.pattern, tt.repl, tt.all); got != tt.want {
+			t.Errorf("Replace(%q, %q, %q, %v) = %q, want %q", tt.s, tt.pattern, tt.repl, tt.all, got, tt.want)
+		}
+	}
+}
@@ -75,4 +75,58 @@ func RestoreTerminal(fd int, state *syscall.Termios) {
 		uintptr(unsafe.Pointer(state)),
 		0, 0, 0,
 	)
-}
\ No newline at end of file
+}
+
+// IsTerminal reports whether fd refers to a terminal.
+func IsTerminal(fd int) bool {
+	var state syscall.Termios
+	_, _, err := syscall.Syscall6(
+		syscall.SYS_IOCTL,
+		uintptr(fd),
+		uintptr(syscall.TCGETS),
+		uintptr(unsafe.Pointer(&state)),
+		0, 0, 0,
+	)
+	return err == 0
+}
+
+// SetInputMode switches the terminal between line-at-a-time (canonical)
+// input and byte-at-a-time input, with or without echo, and returns the
+// previous state for RestoreTerminal. The read builtin uses it, as the
+// shell's prompt leaves the terminal in raw mode.
+func SetInputMode(fd int, canonical, echo bool) (*syscall.Termios, error) {
+	var oldState syscall.Termios
+	if _, _, err := syscall.Syscall6(
+		syscall.SYS_IOCTL,
+		uintptr(fd),
+		uintptr(syscall.TCGETS),
+		uintptr(unsafe.Pointer(&oldState)),
+		0, 0, 0,
+	); err != 0 {
+		return nil, err
+	}
+
+	newState := oldState
+	newState.Lflag &^= syscall.ICANON | syscall.ECHO
+	newState.Iflag |= syscall.ICRNL
+	if canonical {
+		newState.Lflag |= syscall.ICANON
+	} else {
+		newState.Cc[syscall.VMIN] = 1
+		newState.Cc[syscall.VTIME] = 0
+	}
+	if echo {
+		newState.Lflag |= syscall.ECHO
+	}
+
+	if _, _, err := syscall.Syscall6(
+		syscall.SYS_IOCTL,
+		uintptr(fd),
+		uintptr(syscall.TCSETS),
+		uintptr(unsafe.Pointer(&newState)),
+		0, 0, 0,
+	); err != 0 {
+		return nil, err
+	}
+	return &oldState, nil
+}
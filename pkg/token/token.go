@@ -11,6 +11,12 @@ const (
 	REDIRECT  = "REDIRECT"  // >, >>, <, 1>, 2>, 2>>
 
 	SEMICOLON = ";"
+	DSEMI     = ";;"  // ends a case item
+	SEMI_AND  = ";&"  // ends a case item, falling through to the next body
+	DSEMI_AND = ";;&" // ends a case item, testing the remaining patterns
+
+	LPAREN = "("
+	RPAREN = ")"
 
 	IF    = "if"
 	THEN  = "then"
@@ -18,6 +24,27 @@ const (
 	ELIF  = "elif"
 	FI    = "fi"
 
+	WHILE = "while"
+	UNTIL = "until"
+	FOR   = "for"
+	IN    = "in"
+	DO    = "do"
+	DONE  = "done"
+	CASE  = "case"
+	ESAC  = "esac"
+
+	FUNCTION = "function"
+	LBRACE   = "{"
+	RBRACE   = "}"
+
+	BANG = "!"    // negates the status of a pipeline
+	TIME = "time" // times a pipeline
+
+	ARITH = "ARITH" // ((expr)), the literal holds expr
+
+	DLBRACK = "[[" // starts a conditional expression
+	DRBRACK = "]]" // ends it
+
 	NEWLINE = "NEWLINE"
 
 	AND       = "&&"
@@ -31,6 +58,22 @@ var keywords = map[string]TokenType{
 	"else":  ELSE,
 	"elif":  ELIF,
 	"fi":    FI,
+	"while": WHILE,
+	"until": UNTIL,
+	"for":   FOR,
+	"in":    IN,
+	"do":    DO,
+	"done":  DONE,
+	"case":  CASE,
+	"esac":  ESAC,
+
+	"function": FUNCTION,
+	"{":        LBRACE,
+	"}":        RBRACE,
+	"!":        BANG,
+	"time":     TIME,
+	"[[":       DLBRACK,
+	"]]":       DRBRACK,
 }
 
 type Token struct {
@@ -45,6 +88,16 @@ func LookupIdent(ident string) TokenType {
 	return WORD
 }
 
+// IsKeyword reports whether t is one of the reserved words.
+func IsKeyword(t TokenType) bool {
+	for _, k := range keywords {
+		if k == t {
+			return true
+		}
+	}
+	return false
+}
+
 func IsDelimiter(ch byte) bool {
 	return (ch == ' ' ||
 		ch == '\t' ||
@@ -54,5 +107,7 @@ func IsDelimiter(ch byte) bool {
 		ch == '<' ||
 		ch == '\n' ||
 		ch == '&' ||
+		ch == '(' ||
+		ch == ')' ||
 		ch == '\r')
 }
\ No newline at end of file
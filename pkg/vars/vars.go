@@ -0,0 +1,633 @@
+package vars
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/shell-starter-go/pkg/arith"
+)
+
+// Variable is a single shell variable and its attributes.
+type Variable struct {
+	Value string
+
+	// Indexed and Assoc hold the elements of an indexed or associative
+	// array; at most one of them is non-nil. Value is unused for arrays.
+	Indexed map[int64]string
+	Assoc   map[string]string
+
+	Exported bool
+	ReadOnly bool
+	Integer  bool // assignments are evaluated as arithmetic
+	Lower    bool // assignments are converted to lower case
+	Upper    bool // assignments are converted to upper case
+
+	// Unset marks a variable that has attributes but no value yet, as after
+	// "export NAME" or "local NAME".
+	Unset bool
+}
+
+// Store holds the shell's variables. Variables inherited from the process
+// environment start out exported.
+//
+// Scoping is dynamic, like bash: each function call pushes a scope for its
+// local variables, and a lookup sees the innermost variable of that name.
+type Store struct {
+	scopes []map[string]*Variable // scopes[0] holds the globals
+	lock   sync.RWMutex
+}
+
+func New() *Store {
+	return &Store{scopes: []map[string]*Variable{make(map[string]*Variable)}}
+}
+
+// FromEnviron returns a store seeded with the process environment.
+func FromEnviron() *Store {
+	s := New()
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && IsName(name) {
+			s.scopes[0][name] = &Variable{Value: value, Exported: true}
+		}
+	}
+	return s
+}
+
+// Clone returns an independent copy of the store, for a subshell.
+func (s *Store) Clone() *Store {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c := &Store{scopes: make([]map[string]*Variable, len(s.scopes))}
+	for i, scope := range s.scopes {
+		c.scopes[i] = make(map[string]*Variable, len(scope))
+		for name, v := range scope {
+			c.scopes[i][name] = v.clone()
+		}
+	}
+	return c
+}
+
+// lookup returns the innermost variable called name. The caller must hold
+// the lock.
+func (s *Store) lookup(name string) (*Variable, int) {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if v, ok := s.scopes[i][name]; ok {
+			return v, i
+		}
+	}
+	return nil, -1
+}
+
+// Get returns the value of a variable, or of one element for a subscripted
+// name such as "arr[2]". An array without a subscript means its element 0.
+func (s *Store) Get(name string) (string, bool) {
+	name, sub, subscripted := splitSubscript(name)
+	v, ok := s.Var(name)
+	if !ok || v.Unset {
+		return "", false
+	}
+	key := "0"
+	if subscripted {
+		var err error
+		if key, err = s.resolveKey(name, v, sub); err != nil {
+			return "", false
+		}
+	}
+	return v.element(key)
+}
+
+// Var returns a copy of the innermost variable called name.
+func (s *Store) Var(name string) (Variable, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	v, _ := s.lookup(name)
+	if v == nil {
+		return Variable{}, false
+	}
+	return *v.clone(), true
+}
+
+// Set assigns to the innermost variable called name, creating a global one
+// if it does not exist yet. A subscripted name such as "arr[2]" assigns one
+// element of an array. The variable's attributes are applied to the value,
+// and assigning to a readonly variable fails.
+func (s *Store) Set(name, value string) error {
+	return s.assign(name, value, false)
+}
+
+// Append implements "name+=value": it adds to the value of an integer
+// variable and appends to the value of any other.
+func (s *Store) Append(name, value string) error {
+	return s.assign(name, value, true)
+}
+
+func (s *Store) assign(name, value string, appending bool) error {
+	name, sub, subscripted := splitSubscript(name)
+	attrs, _ := s.Var(name)
+	if attrs.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+
+	// Work outside the lock: subscripts and values of integer variables
+	// are arithmetic expressions that may read other variables
+	key := "0"
+	if subscripted {
+		var err error
+		if key, err = s.resolveKey(name, attrs, sub); err != nil {
+			return err
+		}
+	}
+	if appending {
+		old, _ := attrs.element(key)
+		if attrs.Integer {
+			value = old + "+(" + value + ")"
+		} else {
+			value = old + value
+		}
+	}
+	value, err := s.convert(attrs, value)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	v, _ := s.lookup(name)
+	if v == nil {
+		v = &Variable{Unset: true}
+		s.scopes[0][name] = v
+	}
+	v.setElement(key, value, subscripted)
+	v.Unset = false
+	return nil
+}
+
+// Element is one item of a compound array assignment "name=(...)": a plain
+// value that goes under the next index, or "[Key]=Value" when Keyed is set.
+type Element struct {
+	Key   string
+	Keyed bool
+	Value string
+}
+
+// SetArray assigns a whole array. Without appending the old elements are
+// removed first. An associative array needs a key for every element.
+func (s *Store) SetArray(name string, elems []Element, appending bool) error {
+	attrs, ok := s.Var(name)
+	if !ok {
+		attrs.Unset = true
+	}
+	if attrs.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+
+	arr := &attrs
+	if !appending {
+		arr = &Variable{Indexed: map[int64]string{}}
+		if attrs.Assoc != nil {
+			arr = &Variable{Assoc: map[string]string{}}
+		}
+	} else if !arr.IsArray() {
+		arr.Indexed = map[int64]string{}
+		if !attrs.Unset {
+			arr.Indexed[0] = attrs.Value
+		}
+	}
+
+	next := arr.maxIndex() + 1
+	for _, e := range elems {
+		key := strconv.FormatInt(next, 10)
+		if e.Keyed {
+			var err error
+			if key, err = s.resolveKey(name, *arr, e.Key); err != nil {
+				return err
+			}
+		} else if arr.Assoc != nil {
+			return fmt.Errorf("%s: %s: must use subscript when assigning associative array", name, e.Value)
+		}
+		value := e.Value
+		if appending && e.Keyed {
+			old, _ := arr.element(key)
+			value = old + value
+		}
+		value, err := s.convert(attrs, value)
+		if err != nil {
+			return err
+		}
+		arr.setElement(key, value, true)
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil && arr.Indexed != nil {
+			next = n + 1
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	v, _ := s.lookup(name)
+	if v == nil {
+		v = &Variable{}
+		s.scopes[0][name] = v
+	}
+	v.Indexed, v.Assoc = arr.Indexed, arr.Assoc
+	v.Value = ""
+	v.Unset = false
+	return nil
+}
+
+// convert applies the integer and case attributes of v to a value being
+// assigned to it.
+func (s *Store) convert(v Variable, value string) (string, error) {
+	if v.Integer {
+		n, err := arith.Eval(value, s)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", value, err)
+		}
+		value = strconv.FormatInt(n, 10)
+	}
+	if v.Lower {
+		value = strings.ToLower(value)
+	}
+	if v.Upper {
+		value = strings.ToUpper(value)
+	}
+	return value, nil
+}
+
+// resolveKey turns a subscript of v into the key of an element. For an
+// associative array the subscript is the key; otherwise it is an
+// arithmetic expression, and negative indexes count back from the end.
+func (s *Store) resolveKey(name string, v Variable, sub string) (string, error) {
+	if v.Assoc != nil {
+		return sub, nil
+	}
+	n, err := arith.Eval(sub, s)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", sub, err)
+	}
+	if n < 0 {
+		n += v.maxIndex() + 1
+		if n < 0 {
+			return "", fmt.Errorf("%s[%s]: bad array subscript", name, sub)
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// Unset removes the innermost variable called name, or a single element for
+// a subscripted name.
+func (s *Store) Unset(name string) error {
+	name, sub, subscripted := splitSubscript(name)
+	key := ""
+	if subscripted {
+		v, ok := s.Var(name)
+		if !ok {
+			return nil
+		}
+		var err error
+		if key, err = s.resolveKey(name, v, sub); err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	v, i := s.lookup(name)
+	if v == nil {
+		return nil
+	}
+	if v.ReadOnly {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+	if !subscripted {
+		delete(s.scopes[i], name)
+		return nil
+	}
+	switch {
+	case v.Assoc != nil:
+		delete(v.Assoc, key)
+	case v.Indexed != nil:
+		n, _ := strconv.ParseInt(key, 10, 64)
+		delete(v.Indexed, n)
+	case key == "0":
+		delete(s.scopes[i], name)
+	}
+	return nil
+}
+
+// Declare finds the variable called name, creating it unset if needed, and
+// lets fn change its attributes. With local set the variable is looked up
+// and created in the innermost scope only.
+func (s *Store) Declare(name string, local bool, fn func(v *Variable)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var v *Variable
+	if local {
+		v = s.scopes[len(s.scopes)-1][name]
+	} else {
+		v, _ = s.lookup(name)
+	}
+	if v == nil {
+		v = &Variable{Unset: true}
+		if local {
+			s.scopes[len(s.scopes)-1][name] = v
+		} else {
+			s.scopes[0][name] = v
+		}
+	}
+	fn(v)
+}
+
+// PushScope starts a new scope for the local variables of a function call.
+func (s *Store) PushScope() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.scopes = append(s.scopes, make(map[string]*Variable))
+}
+
+// PopScope discards the innermost scope and its local variables.
+func (s *Store) PopScope() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.scopes) > 1 {
+		s.scopes = s.scopes[:len(s.scopes)-1]
+	}
+}
+
+// Names returns the names of all visible variables in sorted order.
+func (s *Store) Names() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, scope := range s.scopes {
+		for name := range scope {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns the exported variables in "NAME=value" form, for the
+// environment of a child process. Arrays cannot be exported.
+func (s *Store) Environ() []string {
+	var env []string
+	for _, name := range s.Names() {
+		if v, ok := s.Var(name); ok && v.Exported && !v.Unset && !v.IsArray() {
+			env = append(env, name+"="+v.Value)
+		}
+	}
+	return env
+}
+
+// IsArray reports whether v is an indexed or associative array.
+func (v *Variable) IsArray() bool {
+	return v.Indexed != nil || v.Assoc != nil
+}
+
+// Keys returns the subscripts of v's elements in order: by index for an
+// indexed array and sorted for an associative one. A scalar with a value
+// has the single key 0.
+func (v *Variable) Keys() []string {
+	var keys []string
+	switch {
+	case v.Assoc != nil:
+		for k := range v.Assoc {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	case v.Indexed != nil:
+		indexes := make([]int64, 0, len(v.Indexed))
+		for n := range v.Indexed {
+			indexes = append(indexes, n)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+		for _, n := range indexes {
+			keys = append(keys, strconv.FormatInt(n, 10))
+		}
+	case !v.Unset:
+		keys = []string{"0"}
+	}
+	return keys
+}
+
+// Values returns the values of v's elements in the order of Keys.
+func (v *Variable) Values() []string {
+	keys := v.Keys()
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i], _ = v.element(k)
+	}
+	return values
+}
+
+func (v *Variable) element(key string) (string, bool) {
+	switch {
+	case v.Assoc != nil:
+		value, ok := v.Assoc[key]
+		return value, ok
+	case v.Indexed != nil:
+		n, _ := strconv.ParseInt(key, 10, 64)
+		value, ok := v.Indexed[n]
+		return value, ok
+	case key == "0" && !v.Unset:
+		return v.Value, true
+	}
+	return "", false
+}
+
+// setElement stores value under key. Assigning to a subscript of a scalar
+// turns it into an indexed array, keeping its value as element 0.
+func (v *Variable) setElement(key, value string, subscripted bool) {
+	if v.Assoc != nil {
+		v.Assoc[key] = value
+		return
+	}
+	n, _ := strconv.ParseInt(key, 10, 64)
+	if v.Indexed == nil && subscripted {
+		v.Indexed = map[int64]string{}
+		if !v.Unset {
+			v.Indexed[0] = v.Value
+		}
+		v.Value = ""
+	}
+	if v.Indexed != nil {
+		v.Indexed[n] = value
+		return
+	}
+	v.Value = value
+}
+
+// maxIndex returns the highest index of an indexed array, or -1 if it has
+// no elements.
+func (v *Variable) maxIndex() int64 {
+	if v.Indexed == nil {
+		if v.Assoc == nil && !v.Unset {
+			return 0
+		}
+		return -1
+	}
+	max := int64(-1)
+	for n := range v.Indexed {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+func (v *Variable) clone() *Variable {
+	c := *v
+	if v.Indexed != nil {
+		c.Indexed = make(map[int64]string, len(v.Indexed))
+		for n, value := range v.Indexed {
+			c.Indexed[n] = value
+		}
+	}
+	if v.Assoc != nil {
+		c.Assoc = make(map[string]string, len(v.Assoc))
+		for k, value := range v.Assoc {
+			c.Assoc[k] = value
+		}
+	}
+	return &c
+}
+
+// splitSubscript splits "name[sub]" into its name and subscript.
+func splitSubscript(name string) (string, string, bool) {
+	i := strings.IndexByte(name, '[')
+	if i <= 0 || !strings.HasSuffix(name, "]") {
+		return name, "", false
+	}
+	return name[:i], name[i+1 : len(name)-1], true
+}
+
+// IsName reports whether s is a valid variable name: a letter or underscore
+// followed by letters, digits and underscores.
+func IsName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			continue
+		}
+		if i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Quote double-quotes a value, escaping the characters that are special
+// inside double quotes.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("\\\"$`", s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FormatArray writes array elements in the "([key]="value" ...)" form that
+// declare -p prints. Elements without a key are written as just "value".
+// ParseArray reads the same form back.
+func FormatArray(elems []Element) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, e := range elems {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if e.Keyed {
+			b.WriteByte('[')
+			if strings.ContainsAny(e.Key, " \t\n\"\\$`]") {
+				b.WriteString(Quote(e.Key))
+			} else {
+				b.WriteString(e.Key)
+			}
+			b.WriteString("]=")
+		}
+		b.WriteString(Quote(e.Value))
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// ParseArray parses an array written by FormatArray. It reports false if s
+// is not in that form.
+func ParseArray(s string) ([]Element, bool) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, false
+	}
+	s = s[1 : len(s)-1]
+
+	elems := []Element{}
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			return elems, true
+		}
+		var e Element
+		if s[0] == '[' {
+			var ok bool
+			if e.Key, s, ok = parseQuoted(s[1:], "]"); !ok || !strings.HasPrefix(s, "]=") {
+				return nil, false
+			}
+			e.Keyed = true
+			s = s[2:]
+		}
+		var ok bool
+		if e.Value, s, ok = parseQuoted(s, " \t\n"); !ok {
+			return nil, false
+		}
+		elems = append(elems, e)
+	}
+}
+
+// parseQuoted reads a value that is either double-quoted as by Quote or
+// runs up to one of the characters in stop, and returns it with the rest of
+// s.
+func parseQuoted(s, stop string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, stop)
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:], true
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}